@@ -0,0 +1,44 @@
+package localcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// atomicTime 可以并发读写的time.Time，只保留到纳秒的时刻，零值表示time.Time{}
+type atomicTime struct {
+	ns atomic.Int64
+}
+
+func (t *atomicTime) Load() time.Time {
+	ns := t.ns.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+func (t *atomicTime) Store(v time.Time) {
+	if v.IsZero() {
+		t.ns.Store(0)
+		return
+	}
+	t.ns.Store(v.UnixNano())
+}
+
+func (t *atomicTime) IsZero() bool {
+	return t.ns.Load() == 0
+}
+
+// atomicDuration 可以并发读写的time.Duration
+type atomicDuration struct {
+	d atomic.Int64
+}
+
+func (d *atomicDuration) Load() time.Duration {
+	return time.Duration(d.d.Load())
+}
+
+func (d *atomicDuration) Store(v time.Duration) {
+	d.d.Store(int64(v))
+}
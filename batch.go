@@ -0,0 +1,223 @@
+package localcache
+
+// SetMany 批量设置/更新缓存内容，整批只加一次写锁
+// 与逐个调用Set相同：未通过校验的value会被跳过，缓存已经Seal时不做任何事
+func (lc *LCache[K, V]) SetMany(items map[K]*V) {
+	if lc.shards != nil {
+		batches := make(map[*LCache[K, V]]map[K]*V)
+		for k, v := range items {
+			shard, k := lc.route(k)
+			if batches[shard] == nil {
+				batches[shard] = make(map[K]*V)
+			}
+			batches[shard][k] = v
+		}
+		for shard, batch := range batches {
+			shard.SetMany(batch)
+		}
+		return
+	}
+	type item struct {
+		k K
+		v *V
+	}
+	batch := make([]item, 0, len(items))
+	for k, v := range items {
+		if lc.validate(v) != nil {
+			continue
+		}
+		batch = append(batch, item{lc.storageKey(k), v})
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	var replaced []evicted[K, V]
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return
+	}
+	for _, it := range batch {
+		if old, ok := lc.store(it.k, it.v, nil); ok {
+			replaced = append(replaced, old)
+		}
+	}
+	lc.lock.Unlock()
+
+	for _, e := range replaced {
+		lc.evict(e)
+	}
+}
+
+// GetMany 批量读取缓存内容，整批只加一次读锁，不存在的key不会出现在结果中
+// 每个key的命中统计以及过期时间的刷新与Get相同
+func (lc *LCache[K, V]) GetMany(keys []K) map[K]*V {
+	if lc.shards != nil {
+		// 按分片分组，结果仍以调用方传入的key返回
+		type batch struct {
+			keys     []K
+			original []K
+		}
+		batches := make(map[*LCache[K, V]]*batch)
+		for _, k := range keys {
+			shard, sk := lc.route(k)
+			b := batches[shard]
+			if b == nil {
+				b = &batch{}
+				batches[shard] = b
+			}
+			b.keys = append(b.keys, sk)
+			b.original = append(b.original, k)
+		}
+		result := make(map[K]*V, len(keys))
+		for shard, b := range batches {
+			values := shard.GetMany(b.keys)
+			for i, sk := range b.keys {
+				if v, ok := values[sk]; ok {
+					result[b.original[i]] = v
+				}
+			}
+		}
+		return result
+	}
+	result := make(map[K]*V, len(keys))
+	if m := lc.sealed.Load(); m != nil {
+		for _, k := range keys {
+			e, ok := (*m)[lc.storageKey(k)]
+			lc.countHit(ok)
+			if ok {
+				result[k] = e.v
+			}
+		}
+		return result
+	}
+
+	var missing []K
+	lc.rlock()
+	for _, k := range keys {
+		n, ok := lc.kvStore[lc.storageKey(k)]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		lc.countHit(true)
+		n.accessCount.Add(1)
+		result[k] = n.v
+		// 刷新缓存时间
+		lc.touch(n)
+	}
+	lc.lock.RUnlock()
+
+	for _, k := range missing {
+		sk := lc.storageKey(k)
+		if v, ok := lc.resurrect(sk); ok {
+			lc.countHit(true)
+			result[k] = v
+			continue
+		}
+		lc.countHit(false)
+		lc.recordMiss(sk)
+	}
+	return result
+}
+
+// GetMulti 批量读取缓存内容，values[i]和found[i]对应keys[i]，不存在的key对应nil和false
+// 与GetMany相同，整批只加一次读锁，命中统计以及过期时间的刷新与Get相同；适用于key的位置有意义的场景
+func (lc *LCache[K, V]) GetMulti(keys []K) (values []*V, found []bool) {
+	values = make([]*V, len(keys))
+	found = make([]bool, len(keys))
+	if lc.shards != nil {
+		// 按分片分组，记录每个key在keys中的位置
+		type batch struct {
+			keys    []K
+			indexes []int
+		}
+		batches := make(map[*LCache[K, V]]*batch)
+		for i, k := range keys {
+			shard, sk := lc.route(k)
+			b := batches[shard]
+			if b == nil {
+				b = &batch{}
+				batches[shard] = b
+			}
+			b.keys = append(b.keys, sk)
+			b.indexes = append(b.indexes, i)
+		}
+		for shard, b := range batches {
+			vs, fs := shard.GetMulti(b.keys)
+			for j, i := range b.indexes {
+				values[i], found[i] = vs[j], fs[j]
+			}
+		}
+		return values, found
+	}
+	if m := lc.sealed.Load(); m != nil {
+		for i, k := range keys {
+			e, ok := (*m)[lc.storageKey(k)]
+			values[i], found[i] = e.v, ok
+			lc.countHit(ok)
+		}
+		return values, found
+	}
+
+	var missing []int
+	lc.rlock()
+	for i, k := range keys {
+		n, ok := lc.kvStore[lc.storageKey(k)]
+		if !ok {
+			missing = append(missing, i)
+			continue
+		}
+		lc.countHit(true)
+		n.accessCount.Add(1)
+		values[i], found[i] = n.v, true
+		// 刷新缓存时间
+		lc.touch(n)
+	}
+	lc.lock.RUnlock()
+
+	for _, i := range missing {
+		sk := lc.storageKey(keys[i])
+		if v, ok := lc.resurrect(sk); ok {
+			lc.countHit(true)
+			values[i], found[i] = v, true
+			continue
+		}
+		lc.countHit(false)
+		lc.recordMiss(sk)
+	}
+	return values, found
+}
+
+// DelMany 批量删除缓存内容，整批只加一次写锁，每个被删除的key都会触发删除回调
+func (lc *LCache[K, V]) DelMany(keys []K) {
+	if lc.shards != nil {
+		batches := make(map[*LCache[K, V]][]K)
+		for _, k := range keys {
+			shard, k := lc.route(k)
+			batches[shard] = append(batches[shard], k)
+		}
+		for shard, batch := range batches {
+			shard.DelMany(batch)
+		}
+		return
+	}
+	var removed []*lruNode[K, V]
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return
+	}
+	for _, k := range keys {
+		k = lc.storageKey(k)
+		delete(lc.ghosts, k)
+		if n, ok := lc.kvStore[k]; ok {
+			removed = append(removed, lc.removeNode(n)...)
+		}
+	}
+	lc.lock.Unlock()
+
+	lc.notifyDeleted(removed)
+	releaseValues(removed)
+}
@@ -0,0 +1,90 @@
+package localcache
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLCache_Batch(t *testing.T) {
+	var removed []string
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithOnRemove(func(key string, value *int) {
+		removed = append(removed, key)
+	}))
+	defer lc.Close()
+
+	a, b, c := 1, 2, 3
+	lc.SetMany(map[string]*int{"a": &a, "b": &b, "c": &c})
+	if got := lc.Stats().Sets; got != 3 {
+		t.Errorf("Stats() Sets = %v, want %v", got, 3)
+	}
+
+	// 部分命中
+	got := lc.GetMany([]string{"a", "c", "x", "y"})
+	want := map[string]*int{"a": &a, "c": &c}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetMany() = %v, want %v", got, want)
+	}
+	stats := lc.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() Hits, Misses = %v, %v, want %v, %v", stats.Hits, stats.Misses, 2, 2)
+	}
+
+	lc.DelMany([]string{"a", "b", "x"})
+	if !reflect.DeepEqual(removed, []string{"a", "b"}) {
+		t.Errorf("removed = %v, want %v", removed, []string{"a", "b"})
+	}
+	if got := lc.GetMany([]string{"a", "b", "c"}); !reflect.DeepEqual(got, map[string]*int{"c": &c}) {
+		t.Errorf("GetMany() = %v, want %v", got, map[string]*int{"c": &c})
+	}
+	if got := lc.Len(); got != 1 {
+		t.Errorf("Len() = %v, want %v", got, 1)
+	}
+	if err := lc.checkInvariants(); err != nil {
+		t.Errorf("checkInvariants() err = %v, want nil", err)
+	}
+}
+
+func TestLCache_GetMulti(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	a, b, c := 1, 2, 3
+	lc.SetMany(map[string]*int{"a": &a, "b": &b})
+	lc.SetWithTTL("c", &c, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	// 过期的key被清理后视为不存在
+	lc.DeleteExpired()
+
+	values, found := lc.GetMulti([]string{"b", "x", "c", "a", "b"})
+	wantValues := []*int{&b, nil, nil, &a, &b}
+	wantFound := []bool{true, false, false, true, true}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("GetMulti() values = %v, want %v", values, wantValues)
+	}
+	if !reflect.DeepEqual(found, wantFound) {
+		t.Errorf("GetMulti() found = %v, want %v", found, wantFound)
+	}
+	stats := lc.Stats()
+	if stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("Stats() Hits, Misses = %v, %v, want %v, %v", stats.Hits, stats.Misses, 3, 2)
+	}
+
+	// 分片时结果同样与keys的顺序对应
+	sharded := NewCache[string, int](OptWithExpire(time.Second), OptWithShards(4))
+	defer sharded.Close()
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		if i%2 == 0 {
+			sharded.SetVal(keys[i], i)
+		}
+	}
+	values, found = sharded.GetMulti(keys)
+	for i := range keys {
+		if found[i] != (i%2 == 0) || (found[i] && *values[i] != i) {
+			t.Errorf("GetMulti()[%d] = %v, %v, want %v, %v", i, values[i], found[i], i, i%2 == 0)
+		}
+	}
+}
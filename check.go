@@ -0,0 +1,127 @@
+package localcache
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOption NewCacheChecked发现的选项错误，返回的错误都包装了它，可以用errors.Is判断
+var ErrInvalidOption = errors.New("localcache: invalid option")
+
+// NewCacheChecked 创建缓存，除了NewCacheWithError返回的选项错误，还会检查互相矛盾或者取值无效的选项：
+// 未设置或不为正数的过期时间、设置为不为正数的key数量和内存上限、与K、V类型不匹配的回调等，
+// NewCache以及NewCacheWithError会忽略这些选项或者按默认值处理
+func NewCacheChecked[K comparable, V any](opts ...Option) (*LCache[K, V], error) {
+	o, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOptions[K, V](o); err != nil {
+		return nil, err
+	}
+	return build[K, V](o), nil
+}
+
+// checkOptions 检查选项之间的矛盾以及无效的取值
+func checkOptions[K comparable, V any](o *CacheOptions) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: "+format, append([]any{ErrInvalidOption}, args...)...)
+	}
+
+	if o.exp <= 0 {
+		return invalid("expire %v is not positive, every key would expire immediately", o.exp)
+	}
+	if o.maxTTL < 0 {
+		return invalid("max ttl %v is negative", o.maxTTL)
+	}
+	if o.maxTTL > 0 && o.exp > o.maxTTL {
+		return invalid("expire %v exceeds max ttl %v", o.exp, o.maxTTL)
+	}
+	if o.grace < 0 {
+		return invalid("grace period %v is negative", o.grace)
+	}
+	if o.maxIdle < 0 {
+		return invalid("max idle %v is negative", o.maxIdle)
+	}
+	if o.jitter < 0 || o.jitter > 1 {
+		return invalid("ttl jitter %v is out of range [0, 1]", o.jitter)
+	}
+
+	if o.maxSet && o.max <= 0 {
+		return invalid("max keys %d is not positive", o.max)
+	}
+	if o.maxMemorySet && o.maxMemory <= 0 {
+		return invalid("max memory %d is not positive", o.maxMemory)
+	}
+	if o.evictN < 0 {
+		return invalid("evict batch %d is negative", o.evictN)
+	}
+	if o.evictN > 1 && o.max <= 0 {
+		return invalid("evict batch %d requires max keys", o.evictN)
+	}
+	if o.max > 0 && o.evictN > o.max {
+		return invalid("evict batch %d exceeds max keys %d", o.evictN, o.max)
+	}
+	if o.shards < 0 {
+		return invalid("shards %d is negative", o.shards)
+	}
+	if o.shards > 1 && o.max > 0 && o.max < o.shards {
+		return invalid("max keys %d is less than shards %d", o.max, o.shards)
+	}
+	if o.missTrack < 0 {
+		return invalid("miss tracking %d is negative", o.missTrack)
+	}
+	if o.maxEntrySize < 0 {
+		return invalid("max entry size %d is negative", o.maxEntrySize)
+	}
+	if o.maxMemory > 0 && o.maxEntrySize > o.maxMemory {
+		return invalid("max entry size %d exceeds max memory %d", o.maxEntrySize, o.maxMemory)
+	}
+
+	switch o.policy {
+	case LRU, LFU, TwoQueue:
+	default:
+		return invalid("unknown policy %d", o.policy)
+	}
+	if o.probation != 0 {
+		if o.policy != TwoQueue {
+			return invalid("two queue ratio requires the TwoQueue policy, got %v", o.policy)
+		}
+		if o.probation <= 0 || o.probation >= 1 {
+			return invalid("two queue ratio %v is out of range (0, 1)", o.probation)
+		}
+	}
+	if o.retryAttempts < 0 {
+		return invalid("loader retry attempts %d is negative", o.retryAttempts)
+	}
+
+	// 回调以any保存，类型与K、V不匹配时init会忽略它
+	callbacks := []struct {
+		name string
+		fn   any
+		ok   bool
+	}{
+		{"OptWithOnUnreadExpire", o.onUnreadExpire, isType[func(K, *V)](o.onUnreadExpire)},
+		{"OptWithValidator", o.validator, isType[func(*V) error](o.validator)},
+		{"OptWithOnRemove", o.onRemove, isType[func(K, *V)](o.onRemove)},
+		{"OptWithOnEvict", o.onEvict, isType[func(K, *V, EvictReason)](o.onEvict)},
+		{"OptWithKeyStringer", o.keyStringer, isType[func(K) string](o.keyStringer)},
+		{"OptWithKeyTransform", o.keyTransform, isType[func(K) K](o.keyTransform)},
+		{"OptWithSizeEstimator", o.sizer, isType[func(*V) int](o.sizer)},
+		{"OptWithSizer", o.valueSizer, isType[func(*V) int](o.valueSizer)},
+		{"OptWithKeyHasher", o.keyHasher, isType[func(K) uint64](o.keyHasher)},
+	}
+	for _, cb := range callbacks {
+		if cb.fn != nil && !cb.ok {
+			var lc *LCache[K, V]
+			return invalid("%s callback %T does not match %T", cb.name, cb.fn, lc)
+		}
+	}
+	return nil
+}
+
+// isType 返回v是否为T类型
+func isType[T any](v any) bool {
+	_, ok := v.(T)
+	return ok
+}
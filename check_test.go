@@ -0,0 +1,67 @@
+package localcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewCacheChecked(t *testing.T) {
+	exp := OptWithExpire(time.Second)
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"valid", []Option{exp, OptWithMaxKeys(100), OptWithShards(4)}, nil},
+		{"valid memory", []Option{exp, OptWithMaxMemory("1MB"), OptWithMaxEntrySize(1024)}, nil},
+		{"valid two queue", []Option{exp, OptWithPolicy(TwoQueue), OptWithTwoQueueRatio(0.5)}, nil},
+		{"valid callbacks", []Option{exp, OptWithOnRemove(func(key string, value *int) {}), OptWithKeyHasher(func(key string) uint64 { return 0 })}, nil},
+		{"no expire", nil, ErrInvalidOption},
+		{"negative expire", []Option{OptWithExpire(-time.Second)}, ErrInvalidOption},
+		{"expire over max ttl", []Option{exp, OptWithMaxTTL(time.Millisecond)}, ErrInvalidOption},
+		{"negative grace", []Option{exp, OptWithGracePeriod(-time.Second)}, ErrInvalidOption},
+		{"negative max idle", []Option{exp, OptWithMaxIdle(-time.Second)}, ErrInvalidOption},
+		{"jitter out of range", []Option{exp, OptWithTTLJitter(1.5)}, ErrInvalidOption},
+		{"zero max keys", []Option{exp, OptWithMaxKeys(0)}, ErrInvalidOption},
+		{"zero max memory", []Option{exp, OptWithMaxMemory("0")}, ErrInvalidOption},
+		{"evict batch without max keys", []Option{exp, OptWithEvictBatch(10)}, ErrInvalidOption},
+		{"evict batch over max keys", []Option{exp, OptWithMaxKeys(5), OptWithEvictBatch(10)}, ErrInvalidOption},
+		{"negative shards", []Option{exp, OptWithShards(-1)}, ErrInvalidOption},
+		{"max keys under shards", []Option{exp, OptWithMaxKeys(2), OptWithShards(4)}, ErrInvalidOption},
+		{"negative miss tracking", []Option{exp, OptWithMissTracking(-1)}, ErrInvalidOption},
+		{"entry over max memory", []Option{exp, OptWithMaxMemory("1KB"), OptWithMaxEntrySize(4096)}, ErrInvalidOption},
+		{"unknown policy", []Option{exp, OptWithPolicy(Policy(42))}, ErrInvalidOption},
+		{"ratio without two queue", []Option{exp, OptWithTwoQueueRatio(0.5)}, ErrInvalidOption},
+		{"ratio out of range", []Option{exp, OptWithPolicy(TwoQueue), OptWithTwoQueueRatio(1)}, ErrInvalidOption},
+		{"negative retry", []Option{exp, OptWithLoaderRetry(-1, nil)}, ErrInvalidOption},
+		{"callback key mismatch", []Option{exp, OptWithOnRemove(func(key int, value *int) {})}, ErrInvalidOption},
+		{"callback value mismatch", []Option{exp, OptWithValidator(func(value *string) error { return nil })}, ErrInvalidOption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc, err := NewCacheChecked[string, int](tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewCacheChecked() err = %v, want %v", err, tt.wantErr)
+			}
+			if (lc != nil) != (tt.wantErr == nil) {
+				t.Errorf("NewCacheChecked() lc = %v, want non-nil %v", lc, tt.wantErr == nil)
+			}
+			if lc != nil {
+				lc.Close()
+			}
+		})
+	}
+
+	// 选项本身的错误与NewCacheWithError相同，原样返回
+	if _, err := NewCacheChecked[string, int](exp, OptWithMaxMemory("lots")); err == nil || errors.Is(err, ErrInvalidOption) {
+		t.Errorf("NewCacheChecked() err = %v, want parse error", err)
+	}
+
+	// NewCache不做这些检查
+	lc := NewCache[string, int](OptWithMaxKeys(0))
+	defer lc.Close()
+	if got := lc.MaxKeys(); got != 0 {
+		t.Errorf("MaxKeys() = %v, want %v", got, 0)
+	}
+}
@@ -0,0 +1,79 @@
+package localcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// contentionSampleRate 开启锁竞争统计时，每隔多少次加锁采样一次
+const contentionSampleRate = 8
+
+// lockWaitStats 采样得到的加锁等待时间
+type lockWaitStats struct {
+	seq     atomic.Uint64 // 加锁次数，用于采样
+	samples atomic.Uint64
+	total   atomic.Int64
+	max     atomic.Int64
+}
+
+// record 记录一次采样
+func (s *lockWaitStats) record(wait time.Duration) {
+	s.samples.Add(1)
+	s.total.Add(int64(wait))
+	for {
+		old := s.max.Load()
+		if int64(wait) <= old || s.max.CompareAndSwap(old, int64(wait)) {
+			return
+		}
+	}
+}
+
+// avg 平均等待时间
+func (s *lockWaitStats) avg() time.Duration {
+	n := s.samples.Load()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(s.total.Load() / int64(n))
+}
+
+// reset 清空已有的采样
+func (s *lockWaitStats) reset() {
+	s.samples.Store(0)
+	s.total.Store(0)
+	s.max.Store(0)
+}
+
+// OptWithContentionTracking 开启锁竞争统计，采样Get/Set/Del等待读写锁的时间，通过Stats获取
+func OptWithContentionTracking(enable bool) Option {
+	return func(co *CacheOptions) {
+		co.contention = enable
+	}
+}
+
+// sampled 本次加锁是否需要采样
+func (lc *LCache[K, V]) sampled(s *lockWaitStats) bool {
+	return lc.o.contention && s.seq.Add(1)%contentionSampleRate == 0
+}
+
+// rlock 加读锁，开启锁竞争统计时采样等待时间
+func (lc *LCache[K, V]) rlock() {
+	if !lc.sampled(&lc.stats.readWait) {
+		lc.lock.RLock()
+		return
+	}
+	start := time.Now()
+	lc.lock.RLock()
+	lc.stats.readWait.record(time.Since(start))
+}
+
+// wlock 加写锁，开启锁竞争统计时采样等待时间
+func (lc *LCache[K, V]) wlock() {
+	if !lc.sampled(&lc.stats.writeWait) {
+		lc.lock.Lock()
+		return
+	}
+	start := time.Now()
+	lc.lock.Lock()
+	lc.stats.writeWait.record(time.Since(start))
+}
@@ -0,0 +1,54 @@
+package localcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLCache_ContentionTracking(t *testing.T) {
+	tests := []struct {
+		name   string
+		enable bool
+	}{
+		{name: "on", enable: true},
+		{name: "off", enable: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[int, int](OptWithExpire(time.Minute), OptWithContentionTracking(tt.enable))
+			defer lc.Close()
+
+			wg := sync.WaitGroup{}
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					for j := 0; j < 1000; j++ {
+						k := i<<32 | j
+						lc.Set(k, &j)
+						lc.Get(k)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			s := lc.Stats()
+			if !tt.enable {
+				if s.LockWaitSamples != 0 || s.ReadLockWaitMax != 0 || s.WriteLockWaitMax != 0 {
+					t.Errorf("Stats() = %+v, want zero lock wait", s)
+				}
+				return
+			}
+			if want := uint64(8 * 1000 * 2 / contentionSampleRate); s.LockWaitSamples != want {
+				t.Errorf("Stats().LockWaitSamples = %v, want %v", s.LockWaitSamples, want)
+			}
+			if s.ReadLockWaitMax < s.ReadLockWaitAvg || s.WriteLockWaitMax < s.WriteLockWaitAvg {
+				t.Errorf("Stats() = %+v, want max >= avg", s)
+			}
+			if s.WriteLockWaitMax == 0 {
+				t.Errorf("Stats().WriteLockWaitMax = %v, want > 0", s.WriteLockWaitMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package localcache
+
+import "time"
+
+// Increment 将key的值加上delta并返回相加后的值，key不存在或已经过期时以delta写入
+// 读取、相加和写入在同一次写锁内完成，并发调用不会丢失更新。与Set一样刷新过期时间，
+// 单独设置的过期时间、依赖的key、OnExpire回调以及优先级保持不变；缓存中保存的是新分配的value，之前Get返回的指针不受影响
+// 相加后的值未通过校验时不写入，缓存已经Seal时同样不写入，二者都返回当前的值
+func Increment[K comparable](lc *LCache[K, int64], key K, delta int64) int64 {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return Increment(shard, key, delta)
+	}
+	key = lc.storageKey(key)
+
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		if v, _, ok := lc.getSealed(key); ok && v != nil {
+			return *v
+		}
+		return 0
+	}
+	var (
+		current int64
+		update  func(n *lruNode[K, int64])
+		store   = lc.store
+	)
+	if n, ok := lc.kvStore[key]; ok && !lc.expired(n, time.Now()) && n.v != nil {
+		current = *n.v
+		ttl, persist := n.exp.Load(), n.persist.Load()
+		deps, onExpire, prio := n.deps, n.onExpire, n.prio
+		update = func(n *lruNode[K, int64]) {
+			n.exp.Store(ttl)
+			n.persist.Store(persist)
+			n.deps = deps
+			n.onExpire = onExpire
+			n.prio = prio
+		}
+		// 沿用的过期时间已经浮动过，不再重复浮动
+		store = lc.storeExact
+	}
+	value := current + delta
+	if lc.validate(&value) != nil {
+		lc.lock.Unlock()
+		return current
+	}
+	old, replaced := store(key, &value, update)
+	lc.lock.Unlock()
+
+	if replaced {
+		lc.evict(old)
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package localcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	lc := NewCache[string, int64](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	tests := []struct {
+		key   string
+		delta int64
+		want  int64
+	}{
+		{"a", 5, 5},
+		{"a", 3, 8},
+		{"a", -10, -2},
+		{"b", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := Increment(lc, tt.key, tt.delta); got != tt.want {
+			t.Errorf("Increment(%v, %v) = %v, want %v", tt.key, tt.delta, got, tt.want)
+		}
+	}
+	if v, ok := lc.GetVal("a"); !ok || v != -2 {
+		t.Errorf("GetVal(a) = %v, %v, want %v, %v", v, ok, -2, true)
+	}
+
+	// 单独设置的过期时间保持不变，过期的key从delta重新开始
+	ten := int64(10)
+	lc.SetWithTTL("c", &ten, time.Millisecond*50)
+	if got := Increment(lc, "c", 1); got != 11 {
+		t.Errorf("Increment(c, 1) = %v, want %v", got, 11)
+	}
+	if ttl, _ := lc.TTL("c"); ttl > time.Millisecond*50 {
+		t.Errorf("TTL(c) = %v, want at most %v", ttl, time.Millisecond*50)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if got := Increment(lc, "c", 1); got != 1 {
+		t.Errorf("Increment(c, 1) = %v, want %v", got, 1)
+	}
+}
+
+func TestIncrement_KeepMetadata(t *testing.T) {
+	lc := NewCache[string, int64](
+		OptWithExpire(time.Hour),
+		OptWithTTLJitter(0.5),
+		OptWithCleanupInterval(time.Millisecond*20),
+	)
+	defer lc.Close()
+
+	// 沿用的过期时间不会被反复浮动
+	for i := 0; i < 2000; i++ {
+		Increment(lc, "n", 1)
+	}
+	if ttl, ok := lc.TTL("n"); !ok || ttl < time.Minute*30 || ttl > time.Minute*90 {
+		t.Errorf("TTL(n) = %v, %v, want within %v of %v", ttl, ok, time.Minute*30, time.Hour)
+	}
+
+	// 依赖的key和优先级保持不变
+	v := int64(1)
+	lc.SetVal("parent", 1)
+	lc.SetWithDeps("child", &v, "parent")
+	Increment(lc, "child", 1)
+	lc.Del("parent")
+	if _, ok := lc.Get("child"); ok {
+		t.Errorf("Get(child) after Del(parent) gotOk = %v, want %v", ok, false)
+	}
+	lc.SetWithPriority("prio", &v, 3)
+	Increment(lc, "prio", 1)
+	lc.lock.RLock()
+	prio := lc.kvStore["prio"].prio
+	lc.lock.RUnlock()
+	if prio != 3 {
+		t.Errorf("priority = %v, want %v", prio, 3)
+	}
+
+	// OnExpire回调保持不变
+	expired := make(chan string, 1)
+	lc.SetWithExpireCallback("e", &v, time.Millisecond*50, func(key string, value *int64) {
+		expired <- key
+	})
+	Increment(lc, "e", 1)
+	select {
+	case key := <-expired:
+		if key != "e" {
+			t.Errorf("OnExpire() key = %v, want %v", key, "e")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnExpire() not called after Increment")
+	}
+}
+
+func TestIncrement_Concurrent(t *testing.T) {
+	const (
+		goroutines = 16
+		perWorker  = 1000
+	)
+	for _, shards := range []int{0, 4} {
+		lc := NewCache[string, int64](OptWithExpire(time.Second), OptWithShards(shards))
+
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < perWorker; j++ {
+					Increment(lc, "hits", 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if v, ok := lc.GetVal("hits"); !ok || v != goroutines*perWorker {
+			t.Errorf("shards %d: GetVal(hits) = %v, %v, want %v, %v", shards, v, ok, goroutines*perWorker, true)
+		}
+		lc.Close()
+	}
+}
@@ -0,0 +1,37 @@
+package localcache
+
+// eventBuffer Events返回的channel的缓冲大小
+const eventBuffer = 1024
+
+// CacheEvent key被移出缓存的事件
+type CacheEvent[K comparable] struct {
+	Key  K
+	Type EvictReason // 移出的原因：过期、淘汰、删除、覆盖或清空
+}
+
+// Events 返回接收key被移出缓存事件的channel，重复调用返回同一个channel
+// 事件与OptWithOnEvict的回调一一对应；channel已满时事件被丢弃并计入Stats().DroppedEvents，不会阻塞asyncJob
+// 只有调用Events之后发生的事件才会被发送；Close不会关闭channel
+func (lc *LCache[K, V]) Events() <-chan CacheEvent[K] {
+	lc.eventsOnce.Do(func() {
+		ch := make(chan CacheEvent[K], eventBuffer)
+		for _, shard := range lc.shards {
+			shard.events.Store(&ch)
+		}
+		lc.events.Store(&ch)
+	})
+	return *lc.events.Load()
+}
+
+// publish 发送移出事件，channel已满时丢弃
+func (lc *LCache[K, V]) publish(key K, reason EvictReason) {
+	ch := lc.events.Load()
+	if ch == nil {
+		return
+	}
+	select {
+	case *ch <- CacheEvent[K]{key, reason}:
+	default:
+		lc.stats.droppedEvents.Add(1)
+	}
+}
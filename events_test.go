@@ -0,0 +1,70 @@
+package localcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLCache_Events(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"single", nil},
+		{"shards", []Option{OptWithShards(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[string, int](append(tt.opts, OptWithExpire(time.Millisecond*100), OptWithCleanupInterval(time.Millisecond*20))...)
+			defer lc.Close()
+
+			events := lc.Events()
+			if lc.Events() != events {
+				t.Errorf("Events() returned a different channel")
+			}
+
+			v1, v2 := 1, 2
+			lc.Set("a", &v1)
+			lc.Set("b", &v1)
+			lc.Set("b", &v2)
+			lc.Del("b")
+
+			want := map[CacheEvent[string]]bool{
+				{"a", ReasonExpired}:  true,
+				{"b", ReasonReplaced}: true,
+				{"b", ReasonDeleted}:  true,
+			}
+			timeout := time.After(time.Second)
+			for len(want) > 0 {
+				select {
+				case e := <-events:
+					if !want[e] {
+						t.Fatalf("Events() got unexpected %v", e)
+					}
+					delete(want, e)
+				case <-timeout:
+					t.Fatalf("Events() missing %v", want)
+				}
+			}
+			if got := lc.Stats().DroppedEvents; got != 0 {
+				t.Errorf("Stats() DroppedEvents = %v, want %v", got, 0)
+			}
+		})
+	}
+}
+
+func TestLCache_EventsDropped(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	// 不读取channel，超出缓冲的事件被丢弃，Del不会被阻塞
+	lc.Events()
+	n := 1
+	for i := 0; i < eventBuffer+10; i++ {
+		lc.Set(i, &n)
+		lc.Del(i)
+	}
+	if got := lc.Stats().DroppedEvents; got != 10 {
+		t.Errorf("Stats() DroppedEvents = %v, want %v", got, 10)
+	}
+}
@@ -0,0 +1,217 @@
+package localcache
+
+import "time"
+
+// EvictReason key被移出缓存的原因
+type EvictReason int
+
+const (
+	ReasonExpired  EvictReason = iota // 过期
+	ReasonCapacity                    // 超出key数量或内存上限被淘汰
+	ReasonDeleted                     // 被Del、Transform删除，或者依赖的key被删除
+	ReasonReplaced                    // value被新的写入或者BeginRebuild的新数据覆盖
+	ReasonFlushed                     // 被Clear清空
+	ReasonTaken                       // 被GetAndDelete读取并删除
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonFlushed:
+		return "flushed"
+	case ReasonTaken:
+		return "taken"
+	}
+	return "unknown"
+}
+
+// Policy 超出容量时选择淘汰对象的策略
+type Policy int
+
+const (
+	LRU      Policy = iota // 淘汰最久未被访问的key
+	LFU                    // 淘汰lru表尾附近访问次数最少的key，频繁访问的key不会被一次性的扫描挤出缓存
+	TwoQueue               // 2Q：新写入的key先进入试用队列，被再次访问后才进入主队列，一次性的扫描只会淘汰试用队列中的key
+)
+
+func (p Policy) String() string {
+	switch p {
+	case LRU:
+		return "lru"
+	case LFU:
+		return "lfu"
+	case TwoQueue:
+		return "2q"
+	}
+	return "unknown"
+}
+
+// OptWithPolicy 设置超出容量时的淘汰策略，默认为LRU
+// LFU按key被Get读取的次数选择，在lru表尾附近的一批key中淘汰次数最少的；SetWithPriority设置的优先级仍然优先考虑
+func OptWithPolicy(p Policy) Option {
+	return func(co *CacheOptions) {
+		co.policy = p
+	}
+}
+
+// evictBefore 返回n是否应该先于victim被淘汰，只比较优先级以及淘汰策略，二者相同时返回false
+func (lc *LCache[K, V]) evictBefore(n, victim *lruNode[K, V]) bool {
+	if n.prio != victim.prio {
+		return n.prio < victim.prio
+	}
+	if lc.o.policy == LFU {
+		return n.accessCount.Load() < victim.accessCount.Load()
+	}
+	return false
+}
+
+// OptWithOnEvict 设置key或value被移出缓存时的回调，reason为移出的原因，可以用来释放value关联的外部资源
+// 与OnRemove不同，value被覆盖时也会回调；回调在锁外执行，过期和淘汰的回调由asyncJob按顺序执行，回调中可以再次访问缓存，包括Prune、Clear等需要asyncJob完成的方法
+func OptWithOnEvict[K comparable, V any](fn func(key K, value *V, reason EvictReason)) Option {
+	return func(co *CacheOptions) {
+		co.onEvict = fn
+	}
+}
+
+// evicted 一个被移出缓存、等待回调的value
+type evicted[K comparable, V any] struct {
+	k      K
+	v      *V
+	reason EvictReason
+}
+
+// evict 调用OnEvict回调，并向Events发送事件
+func (lc *LCache[K, V]) evict(e evicted[K, V]) {
+	lc.publish(e.k, e.reason)
+	if lc.onEvict != nil {
+		lc.onEvict(e.k, e.v, e.reason)
+	}
+}
+
+// notifyRemoved 在asyncJob中登记删除回调，由dispatch执行
+func (lc *LCache[K, V]) notifyRemoved(reason EvictReason, nodes ...*lruNode[K, V]) {
+	if lc.onRemove == nil && lc.onEvict == nil && lc.events.Load() == nil {
+		return
+	}
+	for _, n := range nodes {
+		// 回调稍后执行，先取出key和value
+		k, v := n.k, n.v
+		lc.queueCall(func() {
+			if lc.onRemove != nil {
+				lc.onRemove(k, v)
+			}
+			lc.evict(evicted[K, V]{k, v, reason})
+		})
+	}
+}
+
+// notifyDeleted 在调用方的goroutine中触发Del、Transform等主动删除的回调
+func (lc *LCache[K, V]) notifyDeleted(nodes []*lruNode[K, V]) {
+	for _, n := range nodes {
+		if lc.onRemove != nil {
+			lc.onRemove(n.k, n.v)
+		}
+		lc.evict(evicted[K, V]{n.k, n.v, ReasonDeleted})
+	}
+}
+
+// Prune 从lru表尾淘汰最多n个最久未被访问的key，返回实际淘汰的数量，可以在内存紧张时主动释放缓存
+// 被淘汰的key与超出容量时一样触发OnRemove以及原因为ReasonCapacity的OnEvict回调，计入Stats().Evictions；
+// 设置了OptWithShards时n平均分配给各个分片，key不足的分片剩下的份额分给其他分片，只要缓存中还有key就会淘汰满n个；
+// 淘汰的是每个分片内最久未被访问的key，只是近似的全局lru。缓存已经Seal时不做任何事
+func (lc *LCache[K, V]) Prune(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if lc.shards != nil {
+		return lc.spreadShards(n, func(shard *LCache[K, V], n int) int {
+			return shard.Prune(n)
+		})
+	}
+	return lc.evictTail(func(time.Time) int { return n }, false)
+}
+
+// TrimToSize 从lru表尾淘汰最久未被访问的key，直到Len()不超过target，返回淘汰的数量
+// 与Prune的区别是按目标数量而不是淘汰数量计算，已过期的key和负缓存不计入数量，也不会被淘汰，回调和统计与Prune相同；
+// 设置了OptWithShards时先计算所有分片超出target的总数，再把这部分分配给各个分片淘汰
+func (lc *LCache[K, V]) TrimToSize(target int) int {
+	if target < 0 {
+		target = 0
+	}
+	if lc.shards != nil {
+		excess := lc.Len() - target
+		if excess <= 0 {
+			return 0
+		}
+		return lc.spreadShards(excess, func(shard *LCache[K, V], n int) int {
+			return shard.evictTail(func(time.Time) int { return n }, true)
+		})
+	}
+	return lc.evictTail(func(now time.Time) int { return lc.liveLen(now) - target }, true)
+}
+
+// spreadShards 将n平均分配给各个分片，由evict在分片中淘汰，返回淘汰的总数
+// 分片中的key不足以淘汰自己的份额时，差额在下一轮分配给其他仍然淘汰满份额的分片，直到淘汰满n个或者所有分片都没有可淘汰的key
+func (lc *LCache[K, V]) spreadShards(n int, evict func(shard *LCache[K, V], n int) int) int {
+	total := 0
+	active := lc.shards
+	for total < n && len(active) > 0 {
+		remaining := n - total
+		var next []*LCache[K, V]
+		for i, shard := range active {
+			share := shareOf(remaining, len(active), i)
+			if share > 0 {
+				got := evict(shard, share)
+				total += got
+				if got < share {
+					continue
+				}
+			}
+			next = append(next, shard)
+		}
+		active = next
+	}
+	return total
+}
+
+// shareOf 将n平均分成parts份，返回第i份的大小，除不尽的部分分给前面的份
+func shareOf(n, parts, i int) int {
+	share := n / parts
+	if i < n%parts {
+		share++
+	}
+	return share
+}
+
+// evictTail 在asyncJob中从lru表尾淘汰count个key，count在持有写锁时计算，返回实际淘汰的数量
+// live为true时跳过已过期的key和负缓存，只淘汰会计入Len()的key
+func (lc *LCache[K, V]) evictTail(count func(now time.Time) int, live bool) int {
+	var victims []*lruNode[K, V]
+	// 在asyncJob中遍历lru链表，积压的Get刷新已经先处理完
+	lc.runJob(func() {
+		lc.lock.Lock()
+		if lc.sealed.Load() == nil {
+			now := time.Now()
+			n := count(now)
+			for node := lc.lruTail.prev; node != lc.lruHead && len(victims) < n; {
+				prev := node.prev
+				if (!live || lc.listed(node, now)) && lc.evictNode(node) {
+					victims = append(victims, node)
+				}
+				node = prev
+			}
+		}
+		lc.lock.Unlock()
+
+		lc.stats.evictions.Add(uint64(len(victims)))
+		lc.notifyRemoved(ReasonCapacity, victims...)
+	})
+	return len(victims)
+}
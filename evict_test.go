@@ -0,0 +1,414 @@
+package localcache
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLCache_OnEvict(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		counts = make(map[EvictReason]int)
+	)
+	lc := NewCache[string, int](
+		OptWithExpire(time.Millisecond*100),
+		OptWithMaxKeys(3),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			mu.Lock()
+			counts[reason]++
+			mu.Unlock()
+		}),
+	)
+
+	v1, v2 := 1, 2
+	lc.Set("a", &v1)
+	lc.Set("a", &v2) // 覆盖
+	lc.Set("a", &v2) // 同一个value，不算覆盖
+	lc.Set("b", &v1)
+	lc.Set("c", &v1)
+	lc.Set("d", &v1) // 淘汰a
+	lc.DebugString()
+	lc.Del("b")
+	lc.Del("none")
+	time.Sleep(time.Millisecond * 250) // c、d过期
+
+	mu.Lock()
+	defer mu.Unlock()
+	tests := []struct {
+		reason EvictReason
+		want   int
+	}{
+		{ReasonReplaced, 1},
+		{ReasonCapacity, 1},
+		{ReasonDeleted, 1},
+		{ReasonExpired, 2},
+	}
+	for _, tt := range tests {
+		if got := counts[tt.reason]; got != tt.want {
+			t.Errorf("OnEvict(%v) count = %v, want %v", tt.reason, got, tt.want)
+		}
+	}
+}
+
+func TestLCache_OnEvictReentrant(t *testing.T) {
+	var lc *LCache[string, int]
+	done := make(chan struct{}, 4)
+	lc = NewCache[string, int](OptWithExpire(time.Millisecond*50), OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+		// 回调在锁外执行，可以再次访问缓存
+		lc.Get(key)
+		lc.Len()
+		done <- struct{}{}
+	}))
+	defer lc.Close()
+
+	v1, v2 := 1, 2
+	lc.Set("a", &v1)
+	lc.Set("a", &v2)
+	lc.Del("a")
+	lc.Set("b", &v1)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("OnEvict() callback %d not called, deadlock?", i)
+		}
+	}
+}
+
+func TestLCache_OnEvictReentrantJobs(t *testing.T) {
+	src := NewCache[string, int](OptWithExpire(time.Second))
+	defer src.Close()
+	src.SetVal("loaded", 1)
+	var snapshot bytes.Buffer
+	if err := src.SaveToWriter(&snapshot); err != nil {
+		t.Fatalf("SaveToWriter() err = %v", err)
+	}
+
+	// 过期和淘汰的回调中调用需要asyncJob完成的方法
+	calls := map[string]func(lc *LCache[string, int]){
+		"DebugString":   func(lc *LCache[string, int]) { _ = lc.DebugString() },
+		"DeleteExpired": func(lc *LCache[string, int]) { lc.DeleteExpired() },
+		"Prune":         func(lc *LCache[string, int]) { lc.Prune(1) },
+		"TrimToSize":    func(lc *LCache[string, int]) { lc.TrimToSize(1) },
+		"Clear":         func(lc *LCache[string, int]) { lc.Clear() },
+		"PauseExpiry":   func(lc *LCache[string, int]) { lc.PauseExpiry(); lc.ResumeExpiry() },
+		"WarmUp": func(lc *LCache[string, int]) {
+			v := 1
+			lc.WarmUp(map[string]*int{"warm": &v}, time.Second)
+		},
+		"Commit": func(lc *LCache[string, int]) {
+			b := lc.BeginRebuild()
+			b.Set("rebuilt", new(int))
+			b.Commit()
+		},
+		"LoadFromReader": func(lc *LCache[string, int]) {
+			_ = lc.LoadFromReader(bytes.NewReader(snapshot.Bytes()), MergeOverwrite)
+		},
+	}
+	for _, reason := range []EvictReason{ReasonExpired, ReasonCapacity} {
+		for name, call := range calls {
+			t.Run(reason.String()+"/"+name, func(t *testing.T) {
+				var (
+					lc     *LCache[string, int]
+					called atomic.Bool
+				)
+				done := make(chan struct{})
+				lc = NewCache[string, int](
+					OptWithExpire(time.Millisecond*20),
+					OptWithCleanupInterval(time.Millisecond*5),
+					OptWithMaxKeys(2),
+					OptWithOnEvict(func(key string, value *int, r EvictReason) {
+						// call再次触发的回调直接返回
+						if r == reason && called.CompareAndSwap(false, true) {
+							call(lc)
+							close(done)
+						}
+					}),
+				)
+				defer lc.Close()
+
+				for _, k := range []string{"a", "b", "c"} {
+					lc.SetVal(k, 1)
+				}
+				select {
+				case <-done:
+				case <-time.After(time.Second * 5):
+					t.Fatalf("OnEvict() calling %s did not return, deadlock?", name)
+				}
+
+				// asyncJob仍然可以处理之后的操作
+				finished := make(chan struct{})
+				go func() {
+					lc.DeleteExpired()
+					close(finished)
+				}()
+				select {
+				case <-finished:
+				case <-time.After(time.Second * 5):
+					t.Fatalf("DeleteExpired() after %s did not return, deadlock?", name)
+				}
+			})
+		}
+	}
+}
+
+func TestLCache_Prune(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		evicted []int
+	)
+	// channel足够大，Get的刷新不会被丢弃
+	lc := NewCache[int, int](
+		OptWithExpire(time.Second),
+		OptWithUpdateBuffer(64),
+		OptWithOnEvict(func(key int, value *int, reason EvictReason) {
+			if reason != ReasonCapacity {
+				t.Errorf("OnEvict() reason = %v, want %v", reason, ReasonCapacity)
+			}
+			mu.Lock()
+			evicted = append(evicted, key)
+			mu.Unlock()
+		}),
+	)
+	defer lc.Close()
+
+	for i := 0; i < 30; i++ {
+		lc.Set(i, &i)
+	}
+	// 读取0~4，让5~14成为最久未被访问的key
+	for i := 0; i < 5; i++ {
+		lc.Get(i)
+	}
+
+	if got := lc.Prune(10); got != 10 {
+		t.Fatalf("Prune() = %v, want %v", got, 10)
+	}
+	for i := 0; i < 30; i++ {
+		want := i < 5 || i >= 15
+		if got := lc.Contains(i); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", i, got, want)
+		}
+	}
+	mu.Lock()
+	if len(evicted) != 10 {
+		t.Errorf("OnEvict() called %d times, want %d", len(evicted), 10)
+	}
+	mu.Unlock()
+	if got := lc.Stats().Evictions; got != 10 {
+		t.Errorf("Stats() Evictions = %v, want %v", got, 10)
+	}
+
+	if got := lc.Prune(100); got != 20 {
+		t.Errorf("Prune() = %v, want %v", got, 20)
+	}
+	if got := lc.Len(); got != 0 {
+		t.Errorf("Len() = %v, want %v", got, 0)
+	}
+}
+
+func TestLCache_PruneSkewed(t *testing.T) {
+	// 0~7落在0号分片，其余的key各自落在1~3号分片
+	lc := NewCache[int, int](
+		OptWithExpire(time.Second),
+		OptWithShards(4),
+		OptWithKeyHasher(func(key int) uint64 {
+			if key < 8 {
+				return 0
+			}
+			return uint64(key%3 + 1)
+		}),
+	)
+	defer lc.Close()
+	for i := 0; i < 11; i++ {
+		lc.SetVal(i, i)
+	}
+
+	// 1~3号分片各只有一个key，剩下的份额由0号分片淘汰
+	if got := lc.Prune(8); got != 8 {
+		t.Errorf("Prune(8) = %v, want %v", got, 8)
+	}
+	if got := lc.Len(); got != 3 {
+		t.Errorf("Len() = %v, want %v", got, 3)
+	}
+	if got := lc.Prune(100); got != 3 {
+		t.Errorf("Prune(100) = %v, want %v", got, 3)
+	}
+}
+
+func TestLCache_PolicyLFU(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		wantHot bool
+	}{
+		{LRU, false},
+		{LFU, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			lc := NewCache[int, int](OptWithExpire(time.Minute), OptWithMaxKeys(10), OptWithPolicy(tt.policy))
+			defer lc.Close()
+
+			n := 1
+			lc.Set(0, &n)
+			for i := 0; i < 5; i++ {
+				lc.Get(0)
+			}
+
+			// 大量只写入一次的key
+			for i := 1; i <= 100; i++ {
+				lc.Set(i, &n)
+			}
+			lc.DebugString()
+
+			if got := lc.Contains(0); got != tt.wantHot {
+				t.Errorf("Contains(hot) = %v, want %v", got, tt.wantHot)
+			}
+			if got := lc.Len(); got != 10 {
+				t.Errorf("Len() = %v, want %v", got, 10)
+			}
+		})
+	}
+}
+
+func TestLCache_PolicyTwoQueue(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		wantHot bool
+	}{
+		{LRU, false},
+		{TwoQueue, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			// channel足够大，Get的刷新不会被丢弃
+			lc := NewCache[int, int](OptWithExpire(time.Minute), OptWithMaxKeys(20), OptWithPolicy(tt.policy), OptWithUpdateBuffer(256))
+			defer lc.Close()
+
+			// 被再次访问过的热点key
+			n := 1
+			for i := 0; i < 10; i++ {
+				lc.Set(i, &n)
+			}
+			for i := 0; i < 10; i++ {
+				lc.Get(i)
+			}
+			lc.DebugString()
+
+			// 一次性扫描大量key
+			for i := 100; i < 1100; i++ {
+				lc.Set(i, &n)
+			}
+			lc.DebugString()
+
+			for i := 0; i < 10; i++ {
+				if got := lc.Contains(i); got != tt.wantHot {
+					t.Errorf("Contains(%d) = %v, want %v", i, got, tt.wantHot)
+				}
+			}
+			if got := lc.Len(); got != 20 {
+				t.Errorf("Len() = %v, want %v", got, 20)
+			}
+			// 最近扫描的key仍然可以进入缓存
+			if !lc.Contains(1099) {
+				t.Errorf("Contains(%d) = %v, want %v", 1099, false, true)
+			}
+		})
+	}
+}
+
+func TestLCache_TrimToSize(t *testing.T) {
+	var evicted atomic.Int32
+	// channel足够大，Get的刷新不会被丢弃
+	lc := NewCache[int, int](
+		OptWithExpire(time.Second),
+		OptWithUpdateBuffer(64),
+		OptWithOnEvict(func(key int, value *int, reason EvictReason) {
+			evicted.Add(1)
+		}),
+	)
+	defer lc.Close()
+
+	for i := 0; i < 40; i++ {
+		lc.Set(i, &i)
+	}
+	// 读取前20个key，让它们成为最近使用的一半
+	for i := 0; i < 20; i++ {
+		lc.Get(i)
+	}
+
+	if got := lc.TrimToSize(20); got != 20 {
+		t.Fatalf("TrimToSize() = %v, want %v", got, 20)
+	}
+	for i := 0; i < 40; i++ {
+		if got, want := lc.Contains(i), i < 20; got != want {
+			t.Errorf("Contains(%d) = %v, want %v", i, got, want)
+		}
+	}
+	if got := evicted.Load(); got != 20 {
+		t.Errorf("OnEvict() called %d times, want %d", got, 20)
+	}
+
+	// 已经不超过目标数量时不淘汰
+	if got := lc.TrimToSize(30); got != 0 {
+		t.Errorf("TrimToSize() = %v, want %v", got, 0)
+	}
+	if got := lc.Len(); got != 20 {
+		t.Errorf("Len() = %v, want %v", got, 20)
+	}
+}
+
+func TestLCache_TrimToSizeSkewed(t *testing.T) {
+	// 所有key都落在同一个分片
+	lc := NewCache[int, int](
+		OptWithExpire(time.Second),
+		OptWithShards(4),
+		OptWithKeyHasher(func(key int) uint64 { return 0 }),
+	)
+	defer lc.Close()
+	for i := 0; i < 10; i++ {
+		lc.SetVal(i, i)
+	}
+
+	tests := []struct {
+		target int
+		want   int
+	}{
+		{10, 0},
+		{6, 4},
+		{0, 6},
+	}
+	for _, tt := range tests {
+		if got := lc.TrimToSize(tt.target); got != tt.want {
+			t.Errorf("TrimToSize(%d) = %v, want %v", tt.target, got, tt.want)
+		}
+		if got := lc.Len(); got != tt.target {
+			t.Errorf("Len() after TrimToSize(%d) = %v, want %v", tt.target, got, tt.target)
+		}
+	}
+}
+
+func TestLCache_TrimToSizeExpired(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Second), OptWithCleanupInterval(time.Hour))
+	defer lc.Close()
+
+	// 已过期但还没有被清理的key不计入数量
+	n := 0
+	lc.SetWithTTL(0, &n, time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		lc.SetVal(i, i)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if got := lc.TrimToSize(3); got != 0 {
+		t.Errorf("TrimToSize(3) = %v, want %v", got, 0)
+	}
+	if got := lc.TrimToSize(2); got != 1 {
+		t.Errorf("TrimToSize(2) = %v, want %v", got, 1)
+	}
+	if got := lc.Len(); got != 2 {
+		t.Errorf("Len() = %v, want %v", got, 2)
+	}
+}
@@ -0,0 +1,152 @@
+package localcache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryQueue 查找已过期的节点，让清理逻辑与具体的遍历方式解耦，只在asyncJob中使用
+type expiryQueue[K comparable, V any] interface {
+	// update 节点加入lru链表或者过期时刻发生了变化
+	update(n *lruNode[K, V])
+	// remove 节点从lru链表中摘除
+	remove(n *lruNode[K, V])
+	// PeekEarliest 返回最早过期的节点，队列为空时返回nil
+	PeekEarliest() *lruNode[K, V]
+	// PopExpired 从队列中取出在now时已经过期的节点，节点仍然留在lru链表中，由调用方摘除
+	PopExpired(now time.Time) []*lruNode[K, V]
+}
+
+// listExpiryQueue 直接从lru链表的表尾向前查找过期节点
+// 节点总是插入到表头，只要每次插入的过期时刻都不早于之前插入的节点，表尾就一定是最早过期的节点，遇到第一个未过期的节点就可以结束；
+// SetWithTTL等使过期时间不一致时，插入顺序与过期顺序不再一致，需要扫描整个链表，直到某次扫描发现顺序重新一致
+type listExpiryQueue[K comparable, V any] struct {
+	lc        *LCache[K, V]
+	latest    time.Time // 插入节点中最晚的过期时刻
+	unordered bool      // lru链表的顺序与过期顺序是否可能不一致
+}
+
+func (q *listExpiryQueue[K, V]) update(n *lruNode[K, V]) {
+	expAt := n.expAt.Load()
+	if expAt.Before(q.latest) {
+		q.unordered = true
+		return
+	}
+	q.latest = expAt
+}
+
+func (q *listExpiryQueue[K, V]) remove(n *lruNode[K, V]) {}
+
+func (q *listExpiryQueue[K, V]) PeekEarliest() *lruNode[K, V] {
+	if q.unordered {
+		var earliest *lruNode[K, V]
+		for n := q.lc.lruTail.prev; n != q.lc.lruHead; n = n.prev {
+			if !n.expAt.IsZero() && (earliest == nil || n.expAt.Load().Before(earliest.expAt.Load())) {
+				earliest = n
+			}
+		}
+		return earliest
+	}
+	for n := q.lc.lruTail.prev; n != q.lc.lruHead; n = n.prev {
+		if !n.expAt.IsZero() {
+			return n
+		}
+	}
+	return nil
+}
+
+func (q *listExpiryQueue[K, V]) PopExpired(now time.Time) []*lruNode[K, V] {
+	var (
+		expired []*lruNode[K, V]
+		last    time.Time // 从表尾向前，上一个未过期节点的过期时刻
+		ordered = true
+	)
+	for n := q.lc.lruTail.prev; n != q.lc.lruHead; n = n.prev {
+		if n.expAt.IsZero() {
+			// 永不过期的节点
+			continue
+		}
+		expAt := n.expAt.Load()
+		if now.After(expAt) {
+			expired = append(expired, n)
+			continue
+		}
+		if !q.unordered {
+			break
+		}
+		if expAt.Before(last) {
+			ordered = false
+		}
+		last = expAt
+	}
+	if q.unordered && ordered {
+		// 剩下的节点已经按过期顺序排列，之后可以重新在第一个未过期的节点处结束
+		q.unordered = false
+		if last.After(q.latest) {
+			q.latest = last
+		}
+	}
+	return expired
+}
+
+// heapExpiryQueue 按过期时刻维护的最小堆，与lru链表的顺序无关，永不过期的节点不会加入堆中
+type heapExpiryQueue[K comparable, V any] struct {
+	nodes expiryHeap[K, V]
+}
+
+func (q *heapExpiryQueue[K, V]) update(n *lruNode[K, V]) {
+	if n.heapIdx > 0 {
+		heap.Fix(&q.nodes, n.heapIdx-1)
+		return
+	}
+	heap.Push(&q.nodes, n)
+}
+
+func (q *heapExpiryQueue[K, V]) remove(n *lruNode[K, V]) {
+	if n.heapIdx > 0 {
+		heap.Remove(&q.nodes, n.heapIdx-1)
+	}
+}
+
+func (q *heapExpiryQueue[K, V]) PeekEarliest() *lruNode[K, V] {
+	if len(q.nodes) == 0 {
+		return nil
+	}
+	return q.nodes[0]
+}
+
+func (q *heapExpiryQueue[K, V]) PopExpired(now time.Time) []*lruNode[K, V] {
+	var expired []*lruNode[K, V]
+	for len(q.nodes) > 0 && now.After(q.nodes[0].expAt.Load()) {
+		expired = append(expired, heap.Pop(&q.nodes).(*lruNode[K, V]))
+	}
+	return expired
+}
+
+// expiryHeap 实现heap.Interface，节点的heapIdx保存下标+1，0表示不在堆中
+type expiryHeap[K comparable, V any] []*lruNode[K, V]
+
+func (h expiryHeap[K, V]) Len() int { return len(h) }
+
+func (h expiryHeap[K, V]) Less(i, j int) bool { return h[i].expAt.Load().Before(h[j].expAt.Load()) }
+
+func (h expiryHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIdx = i + 1
+	h[j].heapIdx = j + 1
+}
+
+func (h *expiryHeap[K, V]) Push(x any) {
+	n := x.(*lruNode[K, V])
+	n.heapIdx = len(*h) + 1
+	*h = append(*h, n)
+}
+
+func (h *expiryHeap[K, V]) Pop() any {
+	old := *h
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	n.heapIdx = 0
+	*h = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,536 @@
+package localcache
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var expiryQueueCases = []struct {
+	name string
+	opts []Option
+}{
+	{"heap", nil},
+	{"list", []Option{optWithListExpiry()}},
+}
+
+// runExpirySuite 对每一种过期队列实现运行同一组测试
+func runExpirySuite(t *testing.T, fn func(t *testing.T, opts ...Option)) {
+	for _, c := range expiryQueueCases {
+		t.Run(c.name, func(t *testing.T) {
+			fn(t, c.opts...)
+		})
+	}
+}
+
+func TestExpiryQueue_Expired(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Millisecond*200))...)
+
+		n := 1
+		lc.Set("a", &n)
+		lc.Set("b", &n)
+		lc.Set("c", &n)
+		time.Sleep(time.Millisecond * 150)
+		// 通过Get刷新c的过期时间
+		lc.Get("c")
+		time.Sleep(time.Millisecond * 100)
+
+		got, _ := lc.Pairs()
+		if want := []string{"c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Pairs() keys = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestExpiryQueue_PeekEarliest(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Second))...)
+
+		var earliest *lruNode[string, int]
+		lc.runJob(func() { earliest = lc.expq.PeekEarliest() })
+		if earliest != nil {
+			t.Errorf("PeekEarliest() = %v, want nil", earliest.k)
+		}
+
+		n := 1
+		lc.Set("a", &n)
+		lc.Set("b", &n)
+		lc.Set("c", &n)
+		lc.runJob(func() { earliest = lc.expq.PeekEarliest() })
+		if earliest == nil || earliest.k != "a" {
+			t.Errorf("PeekEarliest() = %v, want %v", earliest, "a")
+		}
+	})
+}
+
+func TestExpiryQueue_DelAndEvict(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Millisecond*100), OptWithMaxKeys(3))...)
+
+		n := 1
+		for _, k := range []string{"a", "b", "c", "d"} {
+			lc.Set(k, &n)
+		}
+		time.Sleep(time.Millisecond * 10)
+		lc.Del("d")
+		time.Sleep(time.Millisecond * 10)
+
+		got, _ := lc.Pairs()
+		sort.Strings(got)
+		if want := []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Pairs() keys = %v, want %v", got, want)
+		}
+
+		time.Sleep(time.Millisecond * 200)
+		if got, _ := lc.Pairs(); len(got) != 0 {
+			t.Errorf("Pairs() keys = %v, want empty", got)
+		}
+		lc.runJob(func() {
+			if lc.lruLen != 0 || lc.expq.PeekEarliest() != nil {
+				t.Errorf("lruLen = %v, PeekEarliest() = %v, want empty", lc.lruLen, lc.expq.PeekEarliest())
+			}
+		})
+	})
+}
+
+func TestHeapExpiryQueue(t *testing.T) {
+	q := &heapExpiryQueue[string, int]{}
+	now := time.Now()
+
+	nodes := map[string]*lruNode[string, int]{}
+	for i, k := range []string{"c", "a", "d", "b"} {
+		nodes[k] = &lruNode[string, int]{k: k}
+		nodes[k].expAt.Store(now.Add(time.Duration(i) * time.Second))
+		q.update(nodes[k])
+	}
+	// 调整过期时刻以及移除
+	nodes["a"].expAt.Store(now.Add(-time.Second))
+	q.update(nodes["a"])
+	q.remove(nodes["d"])
+
+	var got []string
+	for _, n := range q.PopExpired(now.Add(time.Hour)) {
+		got = append(got, n.k)
+	}
+	if want := []string{"a", "c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PopExpired() = %v, want %v", got, want)
+	}
+	if q.PeekEarliest() != nil {
+		t.Errorf("PeekEarliest() = %v, want nil", q.PeekEarliest().k)
+	}
+}
+
+func TestExpiryQueue_MixedTTL(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Second))...)
+
+		n := 1
+		// session在表尾，但比之后写入的ratelimit晚过期
+		lc.SetWithTTL("session", &n, time.Second)
+		lc.SetWithTTL("ratelimit", &n, time.Millisecond*50)
+		time.Sleep(time.Millisecond * 150)
+
+		tests := []struct {
+			key  string
+			want EntryState
+		}{
+			{"session", StateFresh},
+			{"ratelimit", StateMissing},
+		}
+		for _, tt := range tests {
+			if _, got := lc.GetState(tt.key); got != tt.want {
+				t.Errorf("GetState(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		}
+	})
+}
+
+func TestExpiryQueue_OutOfOrder(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Second))...)
+
+		// 从表尾到表头：a(长) b(短) c(长) d(短) e(永不过期) f(短)
+		n := 1
+		lc.SetWithTTL("a", &n, time.Second)
+		lc.SetWithTTL("b", &n, time.Millisecond*30)
+		lc.SetWithTTL("c", &n, time.Second)
+		lc.SetWithTTL("d", &n, time.Millisecond*30)
+		lc.GetOrComputeOnce("e", func() (*int, error) { return &n, nil })
+		lc.SetWithTTL("f", &n, time.Millisecond*30)
+		// 读取会刷新过期时间，但不会改变各自的ttl
+		lc.Get("a")
+		time.Sleep(time.Millisecond * 150)
+
+		for _, k := range []string{"b", "d", "f"} {
+			if _, got := lc.GetState(k); got != StateMissing {
+				t.Errorf("GetState(%q) = %v, want %v", k, got, StateMissing)
+			}
+		}
+		for _, k := range []string{"a", "c", "e"} {
+			if _, got := lc.GetState(k); got != StateFresh {
+				t.Errorf("GetState(%q) = %v, want %v", k, got, StateFresh)
+			}
+		}
+	})
+}
+
+func TestListExpiryQueue_Reorder(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), optWithListExpiry())
+	n := 1
+	lc.SetWithTTL("a", &n, time.Second)
+	lc.SetWithTTL("b", &n, time.Millisecond*30)
+	lc.DebugString()
+
+	q := lc.expq.(*listExpiryQueue[string, int])
+	lc.runJob(func() {
+		if !q.unordered {
+			t.Errorf("unordered = %v, want %v", q.unordered, true)
+		}
+	})
+
+	// b过期被清理之后，剩下的节点重新按过期顺序排列
+	time.Sleep(time.Millisecond * 150)
+	lc.runJob(func() {
+		if q.unordered {
+			t.Errorf("unordered = %v, want %v", q.unordered, false)
+		}
+	})
+}
+
+func TestLCache_ResumeExpiryPersist(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 50))
+	n := 1
+	lc.GetOrComputeOnce("a", func() (*int, error) { return &n, nil })
+	lc.PauseExpiry()
+	time.Sleep(time.Millisecond * 10)
+	lc.ResumeExpiry()
+	time.Sleep(time.Millisecond * 100)
+
+	if _, got := lc.GetState("a"); got != StateFresh {
+		t.Errorf("GetState() = %v, want %v", got, StateFresh)
+	}
+}
+
+func TestLCache_FixedExpire(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Millisecond*150), OptWithFixedExpire())...)
+
+		n := 1
+		lc.Set("a", &n)
+		lc.Set("b", &n)
+		deadline := time.Now().Add(time.Millisecond * 150)
+
+		// 反复读取不会顺延过期时刻
+		for time.Now().Before(deadline.Add(-time.Millisecond * 30)) {
+			if _, ok := lc.Get("a"); !ok {
+				t.Fatalf("Get() gotOk = %v, want %v", false, true)
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+		e, ok := lc.GetEntry("b")
+		// 过期时刻由asyncJob在写入后计算，允许少量误差
+		if !ok || e.ExpAt.After(deadline.Add(time.Millisecond*20)) {
+			t.Errorf("GetEntry() ExpAt = %v, %v, want around %v", e.ExpAt, ok, deadline)
+		}
+
+		// 重新写入会重新计算过期时刻
+		lc.Set("b", &n)
+		time.Sleep(time.Millisecond * 100)
+
+		if _, got := lc.GetState("a"); got != StateMissing {
+			t.Errorf("GetState(a) = %v, want %v", got, StateMissing)
+		}
+		if _, got := lc.GetState("b"); got != StateFresh {
+			t.Errorf("GetState(b) = %v, want %v", got, StateFresh)
+		}
+	})
+}
+
+func TestLCache_CleanupInterval(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond*50), OptWithCleanupInterval(time.Millisecond*300))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+
+	// 已经过期，但还没到清理的时间
+	time.Sleep(time.Millisecond * 150)
+	if _, state := lc.GetState("a"); state != StateStale {
+		t.Errorf("GetState() state = %v, want %v", state, StateStale)
+	}
+
+	time.Sleep(time.Millisecond * 250)
+	if _, state := lc.GetState("a"); state != StateMissing {
+		t.Errorf("GetState() state = %v, want %v", state, StateMissing)
+	}
+}
+
+func TestExpiryQueue_ReapBehindRefreshed(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Second), OptWithCleanupInterval(time.Millisecond*20))...)
+		defer lc.Close()
+
+		n := 1
+		for _, k := range []string{"cold1", "cold2", "cold3"} {
+			lc.SetWithTTL(k, &n, time.Millisecond*100)
+		}
+		lc.Set("hot1", &n)
+		lc.Set("hot2", &n)
+
+		// 不断刷新hot，未被读取的cold到期后仍然按时被清理
+		deadline := time.Now().Add(time.Millisecond * 250)
+		for time.Now().Before(deadline) {
+			lc.Get("hot1")
+			lc.Get("hot2")
+			time.Sleep(time.Millisecond * 5)
+		}
+		for _, k := range []string{"cold1", "cold2", "cold3"} {
+			if _, state := lc.GetState(k); state != StateMissing {
+				t.Errorf("GetState(%v) state = %v, want %v", k, state, StateMissing)
+			}
+		}
+		for _, k := range []string{"hot1", "hot2"} {
+			if _, state := lc.GetState(k); state != StateFresh {
+				t.Errorf("GetState(%v) state = %v, want %v", k, state, StateFresh)
+			}
+		}
+	})
+}
+
+func TestLCache_UpdateTTL(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Second), OptWithCleanupInterval(time.Millisecond*20))...)
+		defer lc.Close()
+
+		n := 1
+		lc.SetWithTTL("a", &n, time.Millisecond*100)
+		lc.SetWithTTL("b", &n, time.Millisecond*100)
+		if !lc.UpdateTTL("a", time.Millisecond*400) {
+			t.Fatalf("UpdateTTL() = %v, want %v", false, true)
+		}
+		if lc.UpdateTTL("c", time.Second) {
+			t.Errorf("UpdateTTL() = %v, want %v", true, false)
+		}
+
+		// 超过原来的过期时刻之后a仍然存在
+		time.Sleep(time.Millisecond * 200)
+		if _, state := lc.GetState("a"); state != StateFresh {
+			t.Errorf("GetState(a) state = %v, want %v", state, StateFresh)
+		}
+		if _, state := lc.GetState("b"); state != StateMissing {
+			t.Errorf("GetState(b) state = %v, want %v", state, StateMissing)
+		}
+	})
+}
+
+func TestLCache_SetIfExpired(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond*50), OptWithCleanupInterval(time.Millisecond*300))
+	defer lc.Close()
+
+	v1, v2, v3 := 1, 2, 3
+	lc.Set("a", &v1)
+	if lc.SetIfExpired("a", &v2) {
+		t.Errorf("SetIfExpired() = %v, want %v", true, false)
+	}
+	if v, ok := lc.Peek("a"); !ok || v != &v1 {
+		t.Errorf("Peek() = %v, %v, want %v, %v", v, ok, &v1, true)
+	}
+
+	// 已经过期，但还没到清理的时间
+	time.Sleep(time.Millisecond * 150)
+	if _, state := lc.GetState("a"); state != StateStale {
+		t.Fatalf("GetState() state = %v, want %v", state, StateStale)
+	}
+	if !lc.SetIfExpired("a", &v3) {
+		t.Errorf("SetIfExpired() = %v, want %v", false, true)
+	}
+	if v, ok := lc.Peek("a"); !ok || v != &v3 {
+		t.Errorf("Peek() = %v, %v, want %v, %v", v, ok, &v3, true)
+	}
+
+	if !lc.SetIfExpired("b", &v1) {
+		t.Errorf("SetIfExpired() = %v, want %v", false, true)
+	}
+}
+
+func TestLCache_SetWithExpireCallback(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithCleanupInterval(time.Millisecond*20))
+	defer lc.Close()
+
+	var (
+		mu    sync.Mutex
+		fired = make(map[string]int)
+	)
+	onExpire := func(key string, value *int) {
+		mu.Lock()
+		fired[key]++
+		mu.Unlock()
+	}
+
+	n := 1
+	lc.SetWithExpireCallback("a", &n, time.Millisecond*50, onExpire)
+	lc.SetWithExpireCallback("b", &n, time.Millisecond*50, onExpire)
+	lc.SetWithExpireCallback("c", &n, time.Millisecond*50, onExpire)
+	lc.SetWithTTL("b", &n, time.Millisecond*50) // 覆盖写入清除回调
+	lc.Del("c")                                 // 删除不触发回调
+
+	time.Sleep(time.Millisecond * 300)
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"a": 1}
+	if !reflect.DeepEqual(fired, want) {
+		t.Errorf("onExpire fired = %v, want %v", fired, want)
+	}
+	if lc.Contains("b") {
+		t.Errorf("Contains(b) = %v, want %v", true, false)
+	}
+}
+
+func TestLCache_TTLJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter float64
+		write  func(lc *LCache[int, int], items map[int]*int)
+	}{
+		{"set", 0.2, func(lc *LCache[int, int], items map[int]*int) {
+			for k, v := range items {
+				lc.Set(k, v)
+			}
+		}},
+		{"warmup", 0.2, func(lc *LCache[int, int], items map[int]*int) {
+			lc.WarmUp(items, time.Minute)
+		}},
+		{"none", 0, func(lc *LCache[int, int], items map[int]*int) {
+			lc.WarmUp(items, time.Minute)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[int, int](OptWithExpire(time.Minute), OptWithTTLJitter(tt.jitter), OptWithUpdateBuffer(1024))
+			defer lc.Close()
+
+			items := make(map[int]*int, 1000)
+			for i := 0; i < 1000; i++ {
+				v := i
+				items[i] = &v
+			}
+			tt.write(lc, items)
+
+			var exps []time.Duration
+			lc.runJob(func() {
+				lc.lock.RLock()
+				defer lc.lock.RUnlock()
+				for _, n := range lc.kvStore {
+					exps = append(exps, n.exp.Load())
+				}
+			})
+
+			lo := time.Duration(float64(time.Minute) * (1 - tt.jitter))
+			hi := time.Duration(float64(time.Minute) * (1 + tt.jitter))
+			distinct := make(map[time.Duration]struct{})
+			for _, exp := range exps {
+				if exp < lo || exp > hi {
+					t.Fatalf("exp = %v, want in [%v, %v]", exp, lo, hi)
+				}
+				distinct[exp] = struct{}{}
+			}
+			if tt.jitter == 0 && len(distinct) != 1 {
+				t.Errorf("distinct exp = %v, want %v", len(distinct), 1)
+			}
+			// 随机浮动之后几乎不会有相同的过期时间
+			if tt.jitter > 0 && len(distinct) < 900 {
+				t.Errorf("distinct exp = %v, want >= %v", len(distinct), 900)
+			}
+		})
+	}
+}
+
+func TestLCache_DeleteExpired(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		var expired atomic.Int32
+		lc := NewCache[int, int](append(opts,
+			OptWithExpire(time.Minute),
+			OptWithCleanupInterval(time.Hour),
+			OptWithUpdateBuffer(64),
+			OptWithOnEvict(func(key int, value *int, reason EvictReason) {
+				if reason == ReasonExpired {
+					expired.Add(1)
+				}
+			}),
+		)...)
+		defer lc.Close()
+
+		n := 1
+		for i := 0; i < 10; i++ {
+			lc.SetWithTTL(i, &n, time.Millisecond*10)
+		}
+		for i := 10; i < 15; i++ {
+			lc.Set(i, &n)
+		}
+		time.Sleep(time.Millisecond * 50)
+
+		if got := lc.DeleteExpired(); got != 10 {
+			t.Errorf("DeleteExpired() = %v, want %v", got, 10)
+		}
+		if got := lc.DeleteExpired(); got != 0 {
+			t.Errorf("DeleteExpired() = %v, want %v", got, 0)
+		}
+		if got := expired.Load(); got != 10 {
+			t.Errorf("OnEvict() expired = %v, want %v", got, 10)
+		}
+		if got := lc.Len(); got != 5 {
+			t.Errorf("Len() = %v, want %v", got, 5)
+		}
+	})
+}
+
+func TestLCache_MaxIdle(t *testing.T) {
+	var reasons sync.Map
+	lc := NewCache[string, int](
+		OptWithExpire(time.Hour),
+		OptWithMaxIdle(time.Millisecond*100),
+		OptWithCleanupInterval(time.Millisecond*10),
+		OptWithUpdateBuffer(64),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			reasons.Store(key, reason)
+		}),
+	)
+	defer lc.Close()
+
+	lc.SetVal("active", 1)
+	lc.SetVal("idle", 2)
+	// GetOrComputeOnce写入的key永不过期，也不受空闲清理影响
+	lc.GetOrComputeOnce("persist", func() (*int, error) {
+		v := 3
+		return &v, nil
+	})
+
+	// 定期读取active，idle一直没有被访问
+	for i := 0; i < 8; i++ {
+		time.Sleep(time.Millisecond * 40)
+		lc.Get("active")
+	}
+
+	if !lc.Contains("active") {
+		t.Errorf("Contains(active) = %v, want %v", false, true)
+	}
+	if !lc.Contains("persist") {
+		t.Errorf("Contains(persist) = %v, want %v", false, true)
+	}
+	if lc.Contains("idle") {
+		t.Errorf("Contains(idle) = %v, want %v", true, false)
+	}
+	if reason, _ := reasons.Load("idle"); reason != ReasonExpired {
+		t.Errorf("OnEvict(idle) reason = %v, want %v", reason, ReasonExpired)
+	}
+	if _, ok := reasons.Load("active"); ok {
+		t.Errorf("OnEvict(active) called, want not called")
+	}
+	if got := lc.Stats().Expirations; got != 1 {
+		t.Errorf("Stats() Expirations = %v, want %v", got, 1)
+	}
+}
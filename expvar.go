@@ -0,0 +1,38 @@
+package localcache
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// expvarStats PublishExpvar发布的统计信息
+type expvarStats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Sets        uint64 `json:"sets"`
+	Size        int    `json:"size"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	MemoryBytes int64  `json:"memory_bytes"`
+}
+
+// PublishExpvar 将缓存的统计信息以name发布到expvar，读取时返回与Stats()相同来源的计数以及当前的key数量
+// name已经被占用时返回错误，不会像expvar.Publish那样panic
+func (lc *LCache[K, V]) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("localcache: expvar %q already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		s := lc.Stats()
+		return expvarStats{
+			Hits:        s.Hits,
+			Misses:      s.Misses,
+			Sets:        s.Sets,
+			Size:        lc.Len(),
+			Evictions:   s.Evictions,
+			Expirations: s.Expirations,
+			MemoryBytes: s.MemoryBytes,
+		}
+	}))
+	return nil
+}
@@ -0,0 +1,38 @@
+package localcache
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLCache_PublishExpvar(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	// expvar是全局的，-count大于1时每次使用不同的名字
+	name := fmt.Sprintf("localcache_test_%d", time.Now().UnixNano())
+	if err := lc.PublishExpvar(name); err != nil {
+		t.Fatalf("PublishExpvar() err = %v, want nil", err)
+	}
+	if err := lc.PublishExpvar(name); err == nil {
+		t.Errorf("PublishExpvar() err = %v, want non-nil", err)
+	}
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	lc.Get("a")
+	lc.Get("c")
+
+	var got expvarStats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatalf("json.Unmarshal() err = %v", err)
+	}
+	want := expvarStats{Hits: 1, Misses: 1, Sets: 2, Size: 2}
+	if got != want {
+		t.Errorf("expvar = %+v, want %+v", got, want)
+	}
+}
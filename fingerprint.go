@@ -0,0 +1,29 @@
+package localcache
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// fingerprintPair 计算指纹时编码的一个key-value对
+type fingerprintPair[K comparable, V any] struct {
+	Key   K
+	Value *V
+}
+
+// Fingerprint 计算所有未过期的key-value对的校验值，与写入顺序无关，可用于比较两个缓存的内容是否一致
+// 每个key-value对编码为JSON后用hash计算，结果按异或合并；无法编码为JSON的key-value对使用%#v格式化
+func (lc *LCache[K, V]) Fingerprint(hash func([]byte) uint64) uint64 {
+	keys, values := lc.Pairs()
+
+	var sum uint64
+	for i, k := range keys {
+		p := fingerprintPair[K, V]{Key: k, Value: values[i]}
+		b, err := json.Marshal(p)
+		if err != nil {
+			b = []byte(fmt.Sprintf("%#v", p))
+		}
+		sum ^= hash(b)
+	}
+	return sum
+}
@@ -0,0 +1,42 @@
+package localcache
+
+import (
+	"hash/fnv"
+	"testing"
+	"time"
+)
+
+func fnv64(b []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(b)
+	return h.Sum64()
+}
+
+func TestLCache_Fingerprint(t *testing.T) {
+	keys := []string{"a", "b", "c", "d"}
+	fill := func(order []int, last int) *LCache[string, int] {
+		lc := NewCache[string, int](OptWithExpire(time.Second))
+		for _, i := range order {
+			v := i
+			if i == len(order)-1 {
+				v = last
+			}
+			lc.Set(keys[i], &v)
+		}
+		return lc
+	}
+
+	lc1 := fill([]int{0, 1, 2, 3}, 3)
+	lc2 := fill([]int{3, 1, 0, 2}, 3)
+	lc3 := fill([]int{0, 1, 2, 3}, 4)
+
+	if got, want := lc2.Fingerprint(fnv64), lc1.Fingerprint(fnv64); got != want {
+		t.Errorf("Fingerprint() = %v, want %v", got, want)
+	}
+	if got, other := lc3.Fingerprint(fnv64), lc1.Fingerprint(fnv64); got == other {
+		t.Errorf("Fingerprint() = %v, want different from %v", got, other)
+	}
+	if got := NewCache[string, int]().Fingerprint(fnv64); got != 0 {
+		t.Errorf("Fingerprint() = %v, want 0", got)
+	}
+}
@@ -0,0 +1,64 @@
+package localcache
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Group 在多个缓存之间共享正在进行的加载，同一个key同时只会有一个加载在执行
+// 零值可以直接使用；共享同一个Group的缓存，相同key对应的value类型需要一致
+type Group struct {
+	mu    sync.Mutex
+	calls map[any]*groupCall
+}
+
+// groupCall Group中一次正在进行的加载
+type groupCall struct {
+	wg  sync.WaitGroup
+	val any
+	err error
+}
+
+// do 执行fn，key相同的并发调用等待并共享同一次执行的结果
+func (g *Group) do(key any, fn func() (any, error)) (any, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[any]*groupCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+	c := &groupCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// fn panic时同样要释放call，等待的调用得到ErrLoaderPanic，panic继续传给当前调用方
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.val, c.err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
+
+// OptWithSingleflightGroup 设置共享的Group，使GetOrCompute的去重跨越所有共享它的缓存
+func OptWithSingleflightGroup(g *Group) Option {
+	return func(co *CacheOptions) {
+		co.group = g
+	}
+}
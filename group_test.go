@@ -0,0 +1,78 @@
+package localcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLCache_SingleflightGroup(t *testing.T) {
+	g := &Group{}
+	l1 := NewCache[string, int](OptWithExpire(time.Second), OptWithSingleflightGroup(g))
+	l2 := NewCache[string, int](OptWithExpire(time.Second), OptWithSingleflightGroup(g))
+
+	var calls atomic.Int32
+	compute := func() (*int, error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond * 50)
+		v := 1
+		return &v, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for _, lc := range []*LCache[string, int]{l1, l2, l1, l2} {
+		wg.Add(1)
+		go func(lc *LCache[string, int]) {
+			defer wg.Done()
+			if v, err := lc.GetOrCompute("a", compute); err != nil || v == nil || *v != 1 {
+				t.Errorf("GetOrCompute() = %v, %v, want 1", v, err)
+			}
+		}(lc)
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("compute calls = %v, want 1", got)
+	}
+	for _, lc := range []*LCache[string, int]{l1, l2} {
+		if _, ok := lc.Get("a"); !ok {
+			t.Errorf("Get() gotOk = %v, want %v", ok, true)
+		}
+	}
+}
+
+func TestLCache_SingleflightGroupPanic(t *testing.T) {
+	g := &Group{}
+	l1 := NewCache[string, int](OptWithExpire(time.Second), OptWithSingleflightGroup(g))
+	defer l1.Close()
+	l2 := NewCache[string, int](OptWithExpire(time.Second), OptWithSingleflightGroup(g))
+	defer l2.Close()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("GetOrCompute() panic = %v, want %v", r, "boom")
+			}
+		}()
+		l1.GetOrCompute("a", func() (*int, error) { panic("boom") })
+	}()
+
+	// panic之后共享Group的缓存可以重新加载同一个key
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := l2.GetOrCompute("a", func() (*int, error) {
+			n := 1
+			return &n, nil
+		})
+		if err != nil || v == nil || *v != 1 {
+			t.Errorf("GetOrCompute() = %v, %v, want %v, %v", v, err, 1, nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("GetOrCompute() after panic blocked")
+	}
+}
@@ -0,0 +1,156 @@
+package localcache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// stringSeed 字符串key使用的maphash种子，进程内固定
+var stringSeed = maphash.MakeSeed()
+
+// shortString 短于该长度的字符串使用fnv64a，更长的字符串使用runtime的maphash
+const shortString = 8
+
+// keyHasher 返回K类型key的hash函数，用于将key分配到不同的分片
+// 整数和字符串类型(包括以它们为底层类型的自定义类型)使用不需要反射的快速实现，其他类型退化为reflectHasher
+func keyHasher[K comparable]() func(K) uint64 {
+	var zero K
+	t := reflect.TypeOf(zero)
+	if t == nil {
+		// K是接口类型
+		return reflectHasher[K]()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return func(k K) uint64 {
+			return stringHash(*(*string)(unsafe.Pointer(&k)))
+		}
+	case reflect.Int8, reflect.Uint8:
+		return func(k K) uint64 {
+			return mix64(uint64(*(*uint8)(unsafe.Pointer(&k))))
+		}
+	case reflect.Int16, reflect.Uint16:
+		return func(k K) uint64 {
+			return mix64(uint64(*(*uint16)(unsafe.Pointer(&k))))
+		}
+	case reflect.Int32, reflect.Uint32:
+		return func(k K) uint64 {
+			return mix64(uint64(*(*uint32)(unsafe.Pointer(&k))))
+		}
+	case reflect.Int, reflect.Uint, reflect.Uintptr, reflect.Int64, reflect.Uint64:
+		if t.Size() == 8 {
+			return func(k K) uint64 {
+				return mix64(*(*uint64)(unsafe.Pointer(&k)))
+			}
+		}
+		return func(k K) uint64 {
+			return mix64(uint64(*(*uint32)(unsafe.Pointer(&k))))
+		}
+	}
+	return reflectHasher[K]()
+}
+
+// reflectHasher 适用于任意comparable类型的hash函数，按==的语义逐个字段计算，==相等的key一定得到相同的hash
+// 指针、channel按地址计算而不是指向的内容，浮点数的-0与+0相同，结构体和数组逐个字段/元素计算，接口按动态类型和值计算
+func reflectHasher[K comparable]() func(K) uint64 {
+	return func(k K) uint64 {
+		return mix64(hashValue(fnvOffset64, reflect.ValueOf(&k).Elem()))
+	}
+}
+
+// hashValue 将v并入h，规则与==一致
+func hashValue(h uint64, v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return hashWord(h, 1)
+		}
+		return hashWord(h, 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return hashWord(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return hashWord(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashWord(h, floatBits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return hashWord(hashWord(h, floatBits(real(c))), floatBits(imag(c)))
+	case reflect.String:
+		return hashWord(h, stringHash(v.String()))
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return hashWord(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if v.IsNil() {
+			return hashWord(h, 0)
+		}
+		e := v.Elem()
+		return hashValue(hashWord(h, stringHash(e.Type().String())), e)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h = hashValue(h, v.Index(i))
+		}
+		return h
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			// ==不比较空白字段
+			if t.Field(i).Name == "_" {
+				continue
+			}
+			h = hashValue(h, v.Field(i))
+		}
+		return h
+	}
+	// comparable类型不会走到这里，接口中的动态值不可比较时==本身就会panic
+	panic(fmt.Sprintf("localcache: unhashable key type %v", v.Type()))
+}
+
+// hashWord 将一个64位的值并入h
+func hashWord(h, x uint64) uint64 {
+	return (h ^ x) * fnvPrime64
+}
+
+// floatBits 返回浮点数用于计算hash的bit，-0与+0相同
+func floatBits(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// stringHash 计算字符串的hash，短字符串逐字节计算更快，长字符串交给runtime的maphash
+func stringHash(s string) uint64 {
+	if len(s) < shortString {
+		return fnv64a(s)
+	}
+	return maphash.String(stringSeed, s)
+}
+
+// fnv64a 计算字符串的FNV-1a hash，不产生内存分配
+func fnv64a(s string) uint64 {
+	h := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= fnvPrime64
+	}
+	return h
+}
+
+// mix64 打散整数的各个bit(splitmix64的最后一步)，让连续的整数也能均匀地分布
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
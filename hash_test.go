@@ -0,0 +1,115 @@
+package localcache
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+type testKey struct {
+	id   int
+	name string
+}
+
+type testIntKey int32
+
+// checkDistribution 将keys按hash分配到shards个分片，检查每个分片的数量偏离平均值不超过20%
+func checkDistribution[K comparable](t *testing.T, hash func(K) uint64, keys []K, shards int) {
+	t.Helper()
+
+	counts := make([]int, shards)
+	for _, k := range keys {
+		counts[hash(k)%uint64(shards)]++
+	}
+	avg := len(keys) / shards
+	for i, c := range counts {
+		if c < avg*8/10 || c > avg*12/10 {
+			t.Errorf("shard %d got %d keys, want about %d", i, c, avg)
+		}
+	}
+}
+
+func TestKeyHasher_Distribution(t *testing.T) {
+	const n, shards = 64000, 16
+
+	strs := make([]string, n)
+	ints := make([]int, n)
+	int32s := make([]testIntKey, n)
+	structs := make([]testKey, n)
+	for i := 0; i < n; i++ {
+		strs[i] = fmt.Sprintf("key%d", i)
+		ints[i] = i
+		int32s[i] = testIntKey(i)
+		structs[i] = testKey{i, "k"}
+	}
+
+	t.Run("string", func(t *testing.T) { checkDistribution(t, keyHasher[string](), strs, shards) })
+	t.Run("int", func(t *testing.T) { checkDistribution(t, keyHasher[int](), ints, shards) })
+	t.Run("named_int32", func(t *testing.T) { checkDistribution(t, keyHasher[testIntKey](), int32s, shards) })
+	t.Run("struct", func(t *testing.T) { checkDistribution(t, keyHasher[testKey](), structs, shards) })
+}
+
+func TestReflectHasher_Equal(t *testing.T) {
+	p := &testKey{1, "a"}
+	negZero := math.Copysign(0, -1)
+	tests := []struct {
+		name string
+		a, b any
+	}{
+		{"negative zero", negZero, 0.0},
+		{"negative zero in struct", struct{ f float64 }{negZero}, struct{ f float64 }{0}},
+		{"complex negative zero", complex(negZero, 1), complex(0, 1)},
+		{"array of pointers", [2]*testKey{p, nil}, [2]*testKey{p, nil}},
+		{"blank field", struct {
+			id int
+			_  int
+		}{id: 1}, struct {
+			id int
+			_  int
+		}{id: 1}},
+	}
+	hash := reflectHasher[any]()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.a != tt.b {
+				t.Fatalf("%v != %v", tt.a, tt.b)
+			}
+			if ha, hb := hash(tt.a), hash(tt.b); ha != hb {
+				t.Errorf("reflectHasher() = %v, %v, want equal", ha, hb)
+			}
+		})
+	}
+}
+
+func BenchmarkKeyHasher_String(b *testing.B) {
+	keys := make([]string, 1024)
+	long := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		long[i] = fmt.Sprintf("user:session:%08d:profile", i)
+	}
+
+	b.Run("specialized", func(b *testing.B) {
+		h := keyHasher[string]()
+		for i := 0; i < b.N; i++ {
+			h(keys[i&1023])
+		}
+	})
+	b.Run("specialized_long", func(b *testing.B) {
+		h := keyHasher[string]()
+		for i := 0; i < b.N; i++ {
+			h(long[i&1023])
+		}
+	})
+	b.Run("fnv_long", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fnv64a(long[i&1023])
+		}
+	})
+	b.Run("reflect", func(b *testing.B) {
+		h := reflectHasher[string]()
+		for i := 0; i < b.N; i++ {
+			h(keys[i&1023])
+		}
+	})
+}
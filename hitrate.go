@@ -0,0 +1,81 @@
+package localcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// hitRateBuckets 命中率窗口最多保留的桶数量，每个桶统计一个清理间隔内的命中和未命中
+const hitRateBuckets = 1200
+
+// hitWindow 按清理间隔分桶的命中统计，asyncJob每次定时清理时切换到下一个桶
+type hitWindow struct {
+	buckets [hitRateBuckets]struct {
+		hits   atomic.Uint64
+		misses atomic.Uint64
+	}
+	cur atomic.Uint64 // 当前桶的序号，单调递增，对hitRateBuckets取模得到下标
+}
+
+// add 累加当前桶的命中或未命中次数
+func (w *hitWindow) add(hit bool) {
+	b := &w.buckets[w.cur.Load()%hitRateBuckets]
+	if hit {
+		b.hits.Add(1)
+	} else {
+		b.misses.Add(1)
+	}
+}
+
+// roll 切换到下一个桶，只在asyncJob中调用
+func (w *hitWindow) roll() {
+	next := w.cur.Load() + 1
+	b := &w.buckets[next%hitRateBuckets]
+	b.hits.Store(0)
+	b.misses.Store(0)
+	w.cur.Store(next)
+}
+
+// counts 返回包括当前桶在内最近n个桶的命中和未命中次数
+func (w *hitWindow) counts(n int) (hits, misses uint64) {
+	cur := w.cur.Load()
+	for i := uint64(0); i < uint64(n) && i <= cur; i++ {
+		b := &w.buckets[(cur-i)%hitRateBuckets]
+		hits += b.hits.Load()
+		misses += b.misses.Load()
+	}
+	return hits, misses
+}
+
+// HitRate 返回最近window时间内Get的命中率，没有任何读取时返回0
+// 统计的精度为一个清理间隔(OptWithCleanupInterval，默认50ms)：window按清理间隔向上取整，
+// 并且总是包括当前尚未结束的间隔；最多统计最近1200个清理间隔，默认即最近60秒
+// PauseExpiry期间仍然按间隔切换统计
+func (lc *LCache[K, V]) HitRate(window time.Duration) float64 {
+	hits, misses := lc.windowCounts(window)
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// windowCounts 返回最近window时间内的命中和未命中次数
+func (lc *LCache[K, V]) windowCounts(window time.Duration) (hits, misses uint64) {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			h, m := shard.windowCounts(window)
+			hits += h
+			misses += m
+		}
+		return hits, misses
+	}
+
+	n := int((window + lc.o.cleanup - 1) / lc.o.cleanup)
+	if n < 1 {
+		n = 1
+	}
+	if n > hitRateBuckets {
+		n = hitRateBuckets
+	}
+	return lc.hitWindow.counts(n)
+}
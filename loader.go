@@ -0,0 +1,338 @@
+package localcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLoaderPanic 加载函数panic，等待同一次加载的其他调用得到包装了它的错误，panic本身传给发起加载的调用方
+var ErrLoaderPanic = errors.New("localcache: loader panic")
+
+// EntryState key在缓存中的状态
+type EntryState int
+
+const (
+	StateMissing    EntryState = iota // key不在缓存中
+	StateFresh                        // key在缓存中且未过期
+	StateStale                        // key已过期，但还没有被清理
+	StateRefreshing                   // key正在被加载
+)
+
+func (s EntryState) String() string {
+	switch s {
+	case StateMissing:
+		return "missing"
+	case StateFresh:
+		return "fresh"
+	case StateStale:
+		return "stale"
+	case StateRefreshing:
+		return "refreshing"
+	}
+	return "unknown"
+}
+
+// call 一次正在进行的加载
+type call[V any] struct {
+	done chan struct{} // 加载完成后关闭
+	val  *V
+	err  error
+}
+
+func newCall[V any]() *call[V] {
+	return &call[V]{done: make(chan struct{})}
+}
+
+// loadFailure 一次重试后仍然失败的加载
+type loadFailure struct {
+	err   error
+	until time.Time // 在此之前直接返回err
+}
+
+// GetOrCompute 读取缓存内容，key不存在时调用compute加载并写入缓存
+// 同一个key同时只会有一个compute在执行，其他调用者等待并共享它的结果；compute返回错误时不会缓存
+// 设置了OptWithLoaderRetry时，compute失败会按设置重试
+func (lc *LCache[K, V]) GetOrCompute(key K, compute func() (*V, error)) (*V, error) {
+	return lc.getOrCompute(key, compute, func(value *V) error {
+		return lc.TrySet(key, value)
+	})
+}
+
+// GetOrLoad 读取缓存内容，key不存在时调用loader加载，并以默认的过期时间写入缓存
+// 与GetOrCompute相同：并发未命中同一个key的调用共享一次loader的执行以及它的结果
+func (lc *LCache[K, V]) GetOrLoad(key K, loader func() (*V, error)) (*V, error) {
+	return lc.GetOrCompute(key, loader)
+}
+
+// GetContext 与Get相同，但key正在被GetOrCompute等方法加载时，会等待加载完成并返回加载的结果
+// 等待期间ctx被取消时返回ctx.Err()；调用前ctx已经被取消时直接返回ctx.Err()，不读取缓存
+// 加载失败时返回加载的错误；lru刷新不会阻塞，命中时不需要等待
+func (lc *LCache[K, V]) GetContext(ctx context.Context, key K) (*V, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	if v, ok := lc.Get(key); ok {
+		return v, true, nil
+	}
+
+	lc.flightLock.Lock()
+	c, ok := lc.inflight[lc.storageKey(key)]
+	lc.flightLock.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	select {
+	case <-c.done:
+		if c.err != nil {
+			return nil, false, c.err
+		}
+		return c.val, true, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// GetOrComputeOnce 与GetOrCompute相同，但compute的结果永不过期，适合缓存解析后的配置等不可变的派生数据
+// 同一个key的compute最多成功执行一次；compute返回错误时不会缓存，下次调用会重新执行
+// 永不过期的key仍然会因为超出容量而被淘汰
+func (lc *LCache[K, V]) GetOrComputeOnce(key K, compute func() (*V, error)) (*V, error) {
+	return lc.getOrCompute(key, compute, func(value *V) error {
+		return lc.set(key, value, func(n *lruNode[K, V]) {
+			n.persist.Store(true)
+		})
+	})
+}
+
+// getOrCompute key不存在时调用compute加载，并通过store写入缓存
+func (lc *LCache[K, V]) getOrCompute(key K, compute func() (*V, error), store func(value *V) error) (*V, error) {
+	if v, ok := lc.Get(key); ok {
+		return v, nil
+	}
+
+	// inflight与GetState一样使用转换后的key
+	fk := lc.storageKey(key)
+	lc.flightLock.Lock()
+	if f, ok := lc.failures[fk]; ok {
+		if time.Now().Before(f.until) {
+			lc.flightLock.Unlock()
+			return nil, f.err
+		}
+		delete(lc.failures, fk)
+	}
+	if c, ok := lc.inflight[fk]; ok {
+		lc.flightLock.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := newCall[V]()
+	lc.inflight[fk] = c
+	lc.flightLock.Unlock()
+
+	var failed bool
+	// compute panic时同样要释放inflight，等待的调用得到ErrLoaderPanic，panic继续传给当前调用方
+	defer func() {
+		r := recover()
+		if r != nil {
+			c.val, c.err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+		}
+
+		lc.flightLock.Lock()
+		delete(lc.inflight, fk)
+		if failed && lc.o.retryAttempts > 1 {
+			lc.failures[fk] = loadFailure{
+				err:   c.err,
+				until: time.Now().Add(lc.retryBackoff(lc.o.retryAttempts)),
+			}
+		}
+		lc.flightLock.Unlock()
+		close(c.done)
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	c.val, c.err = lc.load(fk, compute)
+	failed = c.err != nil
+	if c.err == nil {
+		c.err = store(c.val)
+	}
+	return c.val, c.err
+}
+
+// load 调用compute加载key，设置了OptWithSingleflightGroup时与共享Group的其他缓存一起去重
+func (lc *LCache[K, V]) load(key K, compute func() (*V, error)) (*V, error) {
+	if lc.o.group == nil {
+		return lc.computeWithRetry(compute)
+	}
+	val, err := lc.o.group.do(key, func() (any, error) {
+		return lc.computeWithRetry(compute)
+	})
+	v, _ := val.(*V)
+	return v, err
+}
+
+// computeWithRetry 调用compute，失败时按OptWithLoaderRetry的设置等待后重试
+func (lc *LCache[K, V]) computeWithRetry(compute func() (*V, error)) (value *V, err error) {
+	for attempt := 1; ; attempt++ {
+		value, err = compute()
+		if err == nil || attempt >= lc.o.retryAttempts {
+			return value, err
+		}
+		time.Sleep(lc.retryBackoff(attempt))
+	}
+}
+
+// retryBackoff 第attempt次失败后等待的时间
+func (lc *LCache[K, V]) retryBackoff(attempt int) time.Duration {
+	if lc.o.retryBackoff == nil {
+		return 0
+	}
+	return lc.o.retryBackoff(attempt)
+}
+
+// GetState 读取缓存内容以及key当前的状态，不会刷新过期时间
+// key正在被GetOrCompute加载时返回StateRefreshing，此时value为加载前缓存中的值
+func (lc *LCache[K, V]) GetState(key K) (value *V, state EntryState) {
+	key = lc.storageKey(key)
+
+	lc.flightLock.Lock()
+	_, refreshing := lc.inflight[key]
+	lc.flightLock.Unlock()
+
+	value, state = lc.storedState(key)
+	if refreshing {
+		state = StateRefreshing
+	}
+	return value, state
+}
+
+// storedState 返回已经转换过的key在map中的value以及状态，不考虑正在进行的加载
+func (lc *LCache[K, V]) storedState(key K) (value *V, state EntryState) {
+	if lc.shards != nil {
+		return lc.shardOf(key).storedState(key)
+	}
+
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	state = StateMissing
+	// Seal之后快照中的key永不过期
+	if value, sealed, ok := lc.getSealed(key); sealed {
+		if ok {
+			state = StateFresh
+		}
+		return value, state
+	}
+	if n, ok := lc.kvStore[key]; ok {
+		value = n.v
+		state = StateFresh
+		if lc.expired(n, time.Now()) {
+			state = StateStale
+		}
+	}
+	return value, state
+}
+
+// GetOrComputeBatch 批量读取缓存内容，未命中的key一次性交给loader加载并写入缓存
+// loader只会收到未命中、且没有正在被其他调用加载的key；正在加载的key会等待那次加载的结果
+// loader没有返回的key不会出现在结果中；loader返回错误时，结果中只包含其他key的值
+func (lc *LCache[K, V]) GetOrComputeBatch(keys []K, loader func(missing []K) (map[K]*V, error)) (map[K]*V, error) {
+	result := make(map[K]*V, len(keys))
+	var missing []K
+	for _, k := range keys {
+		if v, ok := lc.Get(k); ok {
+			result[k] = v
+		} else {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// 区分需要自己加载的key和正在被其他调用加载的key
+	var (
+		owned   []K
+		calls   = make(map[K]*call[V], len(missing))
+		waiting = make(map[K]*call[V])
+	)
+	lc.flightLock.Lock()
+	for _, k := range missing {
+		fk := lc.storageKey(k)
+		if _, ok := calls[k]; ok {
+			continue
+		}
+		if c, ok := lc.inflight[fk]; ok {
+			waiting[k] = c
+			continue
+		}
+		c := newCall[V]()
+		lc.inflight[fk] = c
+		calls[k] = c
+		owned = append(owned, k)
+	}
+	lc.flightLock.Unlock()
+
+	var err error
+	if len(owned) > 0 {
+		err = lc.loadBatch(owned, calls, result, loader)
+	}
+
+	for k, c := range waiting {
+		<-c.done
+		if c.err != nil {
+			if err == nil {
+				err = c.err
+			}
+			continue
+		}
+		if c.val != nil {
+			result[k] = c.val
+		}
+	}
+	return result, err
+}
+
+// loadBatch 调用loader加载owned中的key并写入缓存，结果写入result以及每个key的call
+// loader panic时同样释放所有key的inflight，等待的调用得到ErrLoaderPanic，panic继续传给当前调用方
+func (lc *LCache[K, V]) loadBatch(owned []K, calls map[K]*call[V], result map[K]*V, loader func(missing []K) (map[K]*V, error)) (err error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			for _, k := range owned {
+				calls[k].val, calls[k].err = nil, fmt.Errorf("%w: %v", ErrLoaderPanic, r)
+			}
+		}
+
+		lc.flightLock.Lock()
+		for _, k := range owned {
+			delete(lc.inflight, lc.storageKey(k))
+		}
+		lc.flightLock.Unlock()
+		for _, k := range owned {
+			close(calls[k].done)
+		}
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	loaded, err := loader(owned)
+	for _, k := range owned {
+		c := calls[k]
+		c.err = err
+		if err == nil {
+			if v, ok := loaded[k]; ok {
+				c.val = v
+				if c.err = lc.TrySet(k, v); c.err == nil {
+					result[k] = v
+				}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,413 @@
+package localcache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLCache_GetOrCompute(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	var calls atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := lc.GetOrCompute("a", func() (*int, error) {
+				calls.Add(1)
+				time.Sleep(time.Millisecond * 50)
+				n := 1
+				return &n, nil
+			})
+			if err != nil || *v != 1 {
+				t.Errorf("GetOrCompute() = %v, %v, want %v, %v", v, err, 1, nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("compute calls = %v, want %v", got, 1)
+	}
+
+	// 加载失败时不缓存
+	errLoad := errors.New("load failed")
+	if _, err := lc.GetOrCompute("b", func() (*int, error) { return nil, errLoad }); err != errLoad {
+		t.Errorf("GetOrCompute() err = %v, want %v", err, errLoad)
+	}
+	if _, ok := lc.Get("b"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_GetOrComputePanic(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	panicked := make(chan any, 1)
+	go func() {
+		defer func() { panicked <- recover() }()
+		lc.GetOrCompute("a", func() (*int, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+
+	// 等待同一次加载的调用得到ErrLoaderPanic
+	<-started
+	waited := make(chan error, 1)
+	go func() {
+		_, err := lc.GetOrCompute("a", func() (*int, error) {
+			return nil, errors.New("unexpected compute")
+		})
+		waited <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+
+	if r := <-panicked; r != "boom" {
+		t.Errorf("GetOrCompute() panic = %v, want %v", r, "boom")
+	}
+	if err := <-waited; !errors.Is(err, ErrLoaderPanic) {
+		t.Errorf("GetOrCompute() err = %v, want %v", err, ErrLoaderPanic)
+	}
+
+	// panic之后key可以被重新加载
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := lc.GetOrCompute("a", func() (*int, error) {
+			n := 1
+			return &n, nil
+		})
+		if err != nil || v == nil || *v != 1 {
+			t.Errorf("GetOrCompute() = %v, %v, want %v, %v", v, err, 1, nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("GetOrCompute() after panic blocked")
+	}
+}
+
+func TestLCache_GetOrLoad(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	var calls atomic.Int32
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			v, err := lc.GetOrLoad("a", func() (*int, error) {
+				calls.Add(1)
+				time.Sleep(time.Millisecond * 20)
+				n := 1
+				return &n, nil
+			})
+			if err != nil || v == nil || *v != 1 {
+				t.Errorf("GetOrLoad() = %v, %v, want %v, %v", v, err, 1, nil)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("loader calls = %v, want %v", got, 1)
+	}
+	if v, ok := lc.Get("a"); !ok || *v != 1 {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+}
+
+func TestLCache_GetContext(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+
+	// 已经取消的ctx直接返回
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok, err := lc.GetContext(cancelled, "a"); ok || err != context.Canceled {
+		t.Errorf("GetContext() gotOk, err = %v, %v, want %v, %v", ok, err, false, context.Canceled)
+	}
+	if v, ok, err := lc.GetContext(context.Background(), "a"); !ok || err != nil || *v != 1 {
+		t.Errorf("GetContext() = %v, %v, %v, want %v, %v, %v", v, ok, err, 1, true, nil)
+	}
+
+	// 等待正在进行的加载
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go lc.GetOrCompute("b", func() (*int, error) {
+		close(started)
+		<-release
+		n := 2
+		return &n, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	begin := time.Now()
+	if _, ok, err := lc.GetContext(ctx, "b"); ok || err != context.DeadlineExceeded {
+		t.Errorf("GetContext() gotOk, err = %v, %v, want %v, %v", ok, err, false, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Millisecond*200 {
+		t.Errorf("GetContext() took %v after deadline", elapsed)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, ok, err := lc.GetContext(context.Background(), "b"); !ok || err != nil || *v != 2 {
+			t.Errorf("GetContext() = %v, %v, %v, want %v, %v, %v", v, ok, err, 2, true, nil)
+		}
+	}()
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	<-done
+}
+
+func TestLCache_GetState(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	if _, state := lc.GetState("a"); state != StateMissing {
+		t.Errorf("GetState() state = %v, want %v", state, StateMissing)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lc.GetOrCompute("a", func() (*int, error) {
+			close(started)
+			<-release
+			n := 1
+			return &n, nil
+		})
+	}()
+
+	<-started
+	if _, state := lc.GetState("a"); state != StateRefreshing {
+		t.Errorf("GetState() state = %v, want %v", state, StateRefreshing)
+	}
+	close(release)
+	<-done
+
+	if v, state := lc.GetState("a"); state != StateFresh || *v != 1 {
+		t.Errorf("GetState() = %v, %v, want %v, %v", *v, state, 1, StateFresh)
+	}
+}
+
+func TestLCache_GetOrComputeOnce(t *testing.T) {
+	runExpirySuite(t, func(t *testing.T, opts ...Option) {
+		lc := NewCache[string, int](append(opts, OptWithExpire(time.Millisecond*50))...)
+
+		var calls atomic.Int32
+		compute := func() (*int, error) {
+			calls.Add(1)
+			time.Sleep(time.Millisecond * 20)
+			n := 1
+			return &n, nil
+		}
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if v, err := lc.GetOrComputeOnce("cfg", compute); err != nil || *v != 1 {
+					t.Errorf("GetOrComputeOnce() = %v, %v, want %v, %v", v, err, 1, nil)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// 远超默认的过期时间后仍然存在，也不会再次执行compute
+		lc.Set("short", new(int))
+		time.Sleep(time.Millisecond * 200)
+		if _, err := lc.GetOrComputeOnce("cfg", compute); err != nil {
+			t.Errorf("GetOrComputeOnce() err = %v, want nil", err)
+		}
+		if got := calls.Load(); got != 1 {
+			t.Errorf("compute calls = %v, want %v", got, 1)
+		}
+		if _, ok := lc.Get("short"); ok {
+			t.Errorf("Get() gotOk = %v, want %v", ok, false)
+		}
+	})
+}
+
+func TestLCache_GetOrComputeBatch(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	n := 0
+	lc.Set("a", &n)
+
+	var (
+		mu    sync.Mutex
+		calls [][]string
+	)
+	loader := func(missing []string) (map[string]*int, error) {
+		mu.Lock()
+		calls = append(calls, append([]string(nil), missing...))
+		mu.Unlock()
+		time.Sleep(time.Millisecond * 50)
+
+		loaded := make(map[string]*int)
+		for _, k := range missing {
+			if k == "none" {
+				continue
+			}
+			v := len(k)
+			loaded[k] = &v
+		}
+		return loaded, nil
+	}
+
+	// 两个并发的批量加载，未命中的key集合完全相同，只会加载一次
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := lc.GetOrComputeBatch([]string{"a", "bb", "ccc", "none"}, loader)
+			if err != nil {
+				t.Errorf("GetOrComputeBatch() err = %v", err)
+				return
+			}
+			want := map[string]int{"a": 0, "bb": 2, "ccc": 3}
+			if len(got) != len(want) {
+				t.Errorf("GetOrComputeBatch() = %v, want %v", got, want)
+			}
+			for k, v := range want {
+				if got[k] == nil || *got[k] != v {
+					t.Errorf("GetOrComputeBatch()[%q] = %v, want %v", k, got[k], v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(calls) != 1 {
+		t.Fatalf("loader calls = %v, want 1", calls)
+	}
+	sort.Strings(calls[0])
+	if want := []string{"bb", "ccc", "none"}; !reflect.DeepEqual(calls[0], want) {
+		t.Errorf("loader missing = %v, want %v", calls[0], want)
+	}
+
+	// 已加载的key直接命中
+	if _, err := lc.GetOrComputeBatch([]string{"bb", "ccc"}, loader); err != nil || len(calls) != 1 {
+		t.Errorf("GetOrComputeBatch() err = %v, loader calls = %v", err, len(calls))
+	}
+}
+
+func TestLCache_LoaderRetry(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithLoaderRetry(3, func(attempt int) time.Duration {
+		return time.Millisecond * 20 * time.Duration(attempt)
+	}))
+
+	errBackend := errors.New("backend")
+	calls := 0
+	flaky := func() (*int, error) {
+		calls++
+		if calls <= 2 {
+			return nil, errBackend
+		}
+		v := 1
+		return &v, nil
+	}
+
+	start := time.Now()
+	v, err := lc.GetOrCompute("a", flaky)
+	if err != nil || v == nil || *v != 1 {
+		t.Fatalf("GetOrCompute() = %v, %v, want 1", v, err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %v, want 3", calls)
+	}
+	if cost := time.Since(start); cost < time.Millisecond*60 {
+		t.Errorf("GetOrCompute() cost = %v, want >= 60ms backoff", cost)
+	}
+	if _, ok := lc.Get("a"); !ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, true)
+	}
+
+	// 重试全部失败后，短时间内的加载直接返回错误，不会再请求后端
+	failing := func() (*int, error) {
+		calls++
+		return nil, errBackend
+	}
+	calls = 0
+	if _, err := lc.GetOrCompute("b", failing); !errors.Is(err, errBackend) || calls != 3 {
+		t.Fatalf("GetOrCompute() err = %v, calls = %v, want %v, 3", err, calls, errBackend)
+	}
+	if _, err := lc.GetOrCompute("b", failing); !errors.Is(err, errBackend) || calls != 3 {
+		t.Errorf("GetOrCompute() err = %v, calls = %v, want negative cached %v, 3", err, calls, errBackend)
+	}
+
+	time.Sleep(time.Millisecond * 80)
+	calls = 0
+	if _, err := lc.GetOrCompute("b", flaky); err != nil || calls != 3 {
+		t.Errorf("GetOrCompute() err = %v, calls = %v, want nil, 3", err, calls)
+	}
+}
+
+func TestLCache_GetOrComputeBatchPanic(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("GetOrComputeBatch() panic = %v, want %v", r, "boom")
+			}
+		}()
+		lc.GetOrComputeBatch([]string{"a", "b"}, func(missing []string) (map[string]*int, error) {
+			panic("boom")
+		})
+	}()
+
+	// panic之后这些key可以被重新加载
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		got, err := lc.GetOrComputeBatch([]string{"a", "b"}, func(missing []string) (map[string]*int, error) {
+			loaded := make(map[string]*int)
+			for _, k := range missing {
+				v := len(k)
+				loaded[k] = &v
+			}
+			return loaded, nil
+		})
+		if err != nil || len(got) != 2 {
+			t.Errorf("GetOrComputeBatch() = %v, %v, want %v keys", got, err, 2)
+		}
+		if _, err := lc.GetOrCompute("a", func() (*int, error) { return nil, errors.New("unexpected compute") }); err != nil {
+			t.Errorf("GetOrCompute() err = %v, want nil", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("GetOrComputeBatch() after panic blocked")
+	}
+}
@@ -1,10 +1,12 @@
 package localcache
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,27 +14,233 @@ type LCache[K comparable, V any] struct {
 	kvStore    map[K]*lruNode[K, V] // 保存数据的hashmap，提供O(1)的查找能力
 	lruHead    *lruNode[K, V]       // lru链表的表头指针
 	lruTail    *lruNode[K, V]       // lru链表的表尾指针
+	lruLen     int                  // lru链表中的节点数量，只在asyncJob中读写
+	expq       expiryQueue[K, V]    // 查找过期节点，只在asyncJob中使用
 	lock       sync.RWMutex         // 保护map的锁
 	ch         chan *lruNode[K, V]  // 异步更新lru链表
+	jobCh      chan func()          // 需要在asyncJob中执行的操作
+	notes      []func()             // 等待dispatch执行的用户回调，只在asyncJob中读写
+	closed     bool                 // 是否已经Close，由lock保护；置位后不再向ch发送
+	exited     chan struct{}        // asyncJob退出时关闭
+	pending    []*lruNode[K, V]     // channel已满时暂存的写入和删除，由lock保护
+	pausedAt   time.Time            // 暂停过期的开始时间，零值表示未暂停，只在asyncJob中读写
+	paused     atomic.Bool          // 过期是否已暂停，读取方据此把所有节点视为未过期
+	flightLock sync.Mutex           // 保护inflight和failures的锁
+	inflight   map[K]*call[V]       // 正在进行的加载
+	failures   map[K]loadFailure    // 重试后仍然失败的加载，在一段时间内直接返回错误
+	missLock   sync.Mutex           // 保护missCounts的锁
+	missCounts map[K]int            // key连续未命中的次数
 	o          CacheOptions
 	keyCounter int
+	versionSeq uint64 // 最近一次分配的版本号，由lock保护
+	stats      cacheStats
+	hitWindow  hitWindow             // 按清理间隔分桶的命中统计，用于HitRate
+	lastErr    atomic.Pointer[error] // asyncJob最近一次遇到的错误
+	dependents map[K]map[K]struct{}  // 反向依赖索引，key -> 依赖它的key
+
+	sealed atomic.Pointer[map[K]sealedEntry[K, V]] // Seal之后的只读快照
+	ghosts map[K]weakRef[V]                        // 因容量被淘汰的value的弱引用，由lock保护
+
+	hasPending atomic.Bool // pending是否非空，asyncJob不持有锁时据此判断是否需要处理
+
+	events     atomic.Pointer[chan CacheEvent[K]] // Events返回的channel，未调用Events时为nil
+	eventsOnce sync.Once
+
+	probHead *lruNode[K, V] // TwoQueue策略的试用队列表头，最近进入的节点，只在asyncJob中读写
+	probTail *lruNode[K, V] // 试用队列表尾
+	probLen  int            // 试用队列中的节点数量
+
+	shards []*LCache[K, V]    // 设置了OptWithShards时的各个分片，此时自身不保存数据，只负责把key路由到分片
+	hash   func(key K) uint64 // 分片路由使用的hash函数
+
+	onUnreadExpire func(key K, value *V)
+	validator      func(value *V) error
+	onRemove       func(key K, value *V)
+	onEvict        func(key K, value *V, reason EvictReason)
+	keyStringer    func(key K) string
+	keyTransform   func(key K) K
+	sizer          func(value *V) int
 }
 
 type lruNode[K comparable, V any] struct {
 	k      K
 	v      *V
-	exp    time.Duration
-	expAt  time.Time
+	exp    atomicDuration // 过期时间，写入方和asyncJob都会读写
+	expAt  atomicTime     // 过期时刻，由asyncJob计算，Get等读取方不持有写锁
 	next   *lruNode[K, V]
 	prev   *lruNode[K, V]
-	rmFlag bool
+	rmFlag atomic.Bool
+	prio   int // 淘汰优先级，数值越小越先被淘汰
+	deps   []K // 该key依赖的key
+
+	heapIdx int         // 在过期堆中的下标+1，0表示不在堆中
+	persist atomic.Bool // 永不过期
+	rearm   atomic.Bool // 写入或ResetTTL之后需要重新计算过期时刻，用于固定过期
+
+	created time.Time // 第一次写入的时刻
+	lastSet time.Time // 最近一次写入的时刻，Get不会修改
+	size    int       // 估算的内存占用
+
+	accessCount atomic.Uint64 // 被Get读取的次数
+	touchedAt   atomicTime    // 最近一次因channel已满被丢弃的Get刷新的时刻
+	accessedAt  atomicTime    // 最近一次被asyncJob移动到lru表头的时刻，用于OptWithMaxIdle
+
+	negative bool                  // 负缓存，表示key在数据源中不存在，value为nil
+	onExpire func(key K, value *V) // 只属于该key的过期回调，覆盖写入时清除
+	version  uint64                // 版本号，value每次被写入时递增
+
+	// TwoQueue策略使用的试用队列，只在asyncJob中读写
+	qprev       *lruNode[K, V]
+	qnext       *lruNode[K, V]
+	inProbation bool // 是否在试用队列中
+	promoted    bool // 是否已经因为再次访问进入主队列
+}
+
+// Entry 缓存中的一个key以及它的元数据
+type Entry[K comparable, V any] struct {
+	Key       K
+	Value     *V
+	ExpAt     time.Time     // 过期时刻，零值表示永不过期或者还未被asyncJob处理
+	LastSet   time.Time     // 最近一次写入的时刻
+	CreatedAt time.Time     // 第一次写入的时刻，之后的覆盖写入不会修改
+	TTL       time.Duration // 过期时间，永不过期时为0
+	Remaining time.Duration // 距离过期的剩余时间，ExpAt为零值时为0
+}
+
+// entry 返回节点在now时对应的Entry，调用方需持有锁
+func (n *lruNode[K, V]) entry(now time.Time) Entry[K, V] {
+	e := Entry[K, V]{
+		Key:       n.k,
+		Value:     n.v,
+		ExpAt:     n.expAt.Load(),
+		LastSet:   n.lastSet,
+		CreatedAt: n.created,
+	}
+	if !n.persist.Load() {
+		e.TTL = n.exp.Load()
+	}
+	if !e.ExpAt.IsZero() {
+		e.Remaining = e.ExpAt.Sub(now)
+	}
+	return e
+}
+
+// expired 返回节点在now时是否已经过期，还未被asyncJob处理过的节点视为未过期
+// 写入或重新设置过期时间之后、asyncJob重新计算过期时刻之前，节点同样视为未过期
+func (n *lruNode[K, V]) expired(now time.Time) bool {
+	ns := n.expAt.ns.Load()
+	return ns != 0 && now.UnixNano() > ns && !n.rearm.Load()
+}
+
+// expired 返回节点n在now时是否已经过期，过期被PauseExpiry暂停期间所有节点都视为未过期
+func (lc *LCache[K, V]) expired(n *lruNode[K, V], now time.Time) bool {
+	return !lc.paused.Load() && n.expired(now)
+}
+
+// listed 返回节点n在now时是否出现在Len、Keys、Range等枚举结果中
+// 负缓存只用于拦截对数据源的查询，不是真正的key，所有枚举都跳过它
+func (lc *LCache[K, V]) listed(n *lruNode[K, V], now time.Time) bool {
+	return !n.negative && !lc.expired(n, now)
 }
 
+const (
+	// evictScanDepth 容量淘汰时，从lru表尾向前查找淘汰对象的最大节点数
+	evictScanDepth = 16
+	// maxDrainBatch asyncJob每次唤醒时最多连续处理的lru更新数量
+	maxDrainBatch = 64
+	// cleanupInterval 默认的定时清理间隔，可以通过OptWithCleanupInterval修改
+	cleanupInterval = time.Millisecond * 50
+	// chanSize lru更新channel默认的缓冲大小，可以通过OptWithUpdateBuffer修改
+	// 积压在channel中的每条消息都会持有一个节点，缓冲不宜过大，以免大量已删除的节点迟迟不能被回收
+	chanSize = 5
+)
+
 // CacheOptions 本地的缓存选项
 type CacheOptions struct {
 	exp       time.Duration // 默认的过期时间
 	max       int           // 缓存的key数量上限
 	maxMemory int           // 缓存的内存上限
+	selfHeal  bool          // 定时清理时是否检查并修复map和lru链表的不一致
+	evictN    int           // 超出key数量上限时一次淘汰的key数量
+	grace     time.Duration // key过期后继续保留的宽限期
+	maxTTL    time.Duration // 过期时间的上限
+	missTrack int           // 最多记录多少个key的连续未命中次数
+	listScan  bool          // 从lru表尾扫描查找过期节点，代替默认的最小堆
+	fixedExp  bool          // 过期时刻只由写入决定，读取不刷新
+	policy    Policy        // 超出容量时选择淘汰对象的策略
+	jitter    float64       // 写入时过期时间随机浮动的比例
+	probation float64       // TwoQueue策略中试用队列占容量的比例
+	maxIdle   time.Duration // 超过这个时长没有被访问的key会被清理
+
+	retryAttempts int                             // 加载失败时最多尝试的次数
+	retryBackoff  func(attempt int) time.Duration // 第attempt次失败后等待的时间
+	group         *Group                          // 多个缓存共享的加载去重
+	weakValues    bool                            // 被淘汰的value是否保留弱引用
+	contention    bool                            // 是否采样统计加锁的等待时间
+	logger        Logger                          // 输出诊断信息
+	updateBuffer  int                             // lru更新channel的缓冲大小
+	err           error                           // 选项中的错误，由NewCacheWithError返回
+	shards        int                             // 分片数量
+	cleanup       time.Duration                   // 定时清理的间隔
+	maxEntrySize  int                             // 单个value估算大小的上限
+
+	onUnreadExpire any // func(K, *V)，key从未被读取就过期时回调
+	validator      any // func(*V) error，写入前校验value
+	onRemove       any // func(K, *V)，key被删除、过期或淘汰时回调
+	onEvict        any // func(K, *V, EvictReason)，key或value被移出缓存时回调
+	keyStringer    any // func(K) string，诊断信息中key的格式化方式
+	keyTransform   any // func(K) K，读写前对key的转换
+	sizer          any // func(*V) int，估算value占用的内存
+	valueSizer     any // func(*V) int，估算value本身占用的内存，不包括每个key固定的额外开销
+	keyHasher      any // func(K) uint64，分片路由使用的hash函数
+
+	maxSet       bool // 是否设置过OptWithMaxKeys，由NewCacheChecked检查
+	maxMemorySet bool // 是否设置过OptWithMaxMemory，由NewCacheChecked检查
+}
+
+// CacheStats 缓存的统计信息
+type CacheStats struct {
+	Hits        uint64 // Get命中的次数
+	Misses      uint64 // Get未命中的次数
+	Sets        uint64 // 写入的次数
+	Evictions   uint64 // 因超出容量被淘汰的key数量
+	Expirations uint64 // 过期被清理的key数量
+
+	UnreadExpirations uint64 // 写入后从未被读取就过期的key数量
+	Repairs           uint64 // 自愈检查修复的不一致节点数量
+	ChannelDepth      int    // lru更新channel中积压的消息数量
+	AsyncErrors       uint64 // asyncJob遇到的错误数量
+	MemoryBytes       int64  // 估算的内存占用，需要设置OptWithMaxMemory或OptWithSizeEstimator
+	SoftHits          uint64 // 通过弱引用找回被淘汰的value的次数
+	DroppedUpdates    uint64 // channel已满时被丢弃的Get刷新次数
+	DroppedEvents     uint64 // Events的channel已满时被丢弃的事件数量
+	Rejected          uint64 // 估算大小超过OptWithMaxEntrySize而没有写入的value数量
+
+	// 采样得到的加锁等待时间，需要设置OptWithContentionTracking
+	LockWaitSamples  uint64
+	ReadLockWaitAvg  time.Duration
+	ReadLockWaitMax  time.Duration
+	WriteLockWaitAvg time.Duration
+	WriteLockWaitMax time.Duration
+}
+
+type cacheStats struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	sets        atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+
+	unreadExpirations atomic.Uint64
+	repairs           atomic.Uint64
+	asyncErrors       atomic.Uint64
+	memory            atomic.Int64
+	softHits          atomic.Uint64
+	droppedUpdates    atomic.Uint64
+	droppedEvents     atomic.Uint64
+	rejected          atomic.Uint64
+	readWait          lockWaitStats
+	writeWait         lockWaitStats
 }
 
 type Option func(co *CacheOptions)
@@ -44,187 +252,2150 @@ func OptWithExpire(exp time.Duration) Option {
 	}
 }
 
+// OptWithFixedExpire 使用固定过期：过期时刻只在写入时确定，Get只调整lru顺序，不再顺延过期时刻
+func OptWithFixedExpire() Option {
+	return func(co *CacheOptions) {
+		co.fixedExp = true
+	}
+}
+
 // OptWithMaxKeys 设置缓存的key数量上限
 func OptWithMaxKeys(max int) Option {
 	return func(co *CacheOptions) {
 		co.max = max
+		co.maxSet = true
 	}
 }
 
-// OptWithMaxMemory 设置缓存的内存上限
+// OptWithMaxMemory 设置缓存的内存上限，例如"512MB"、"1.5 GB"、"100kb"、"4096"
+// 单位为B、KB、MB、GB、TB，不区分大小写，按1024进位，没有单位时按字节计算
+// 格式错误时NewCacheWithError返回错误，NewCache会panic
 func OptWithMaxMemory(maxMemory string) Option {
-	maxMemory = strings.ToUpper(maxMemory)
+	n, err := parseMemory(maxMemory)
+	return func(co *CacheOptions) {
+		if err != nil {
+			co.err = err
+			return
+		}
+		co.maxMemory = n
+		co.maxMemorySet = true
+	}
+}
+
+// OptWithMaxIdle 设置空闲清理：超过d没有被Get读取或写入的key在定时清理时被移出缓存，与过期时间同时生效
+// 空闲的key从lru表尾开始清理，与过期一样触发原因为ReasonExpired的回调并计入Stats().Expirations；
+// 永不过期的key不受影响。d不大于0时不开启
+func OptWithMaxIdle(d time.Duration) Option {
+	return func(co *CacheOptions) {
+		co.maxIdle = d
+	}
+}
+
+// OptWithGracePeriod 设置key过期后继续保留的宽限期，宽限期内的key可以通过StaleKeys找到并在后台重新加载
+func OptWithGracePeriod(grace time.Duration) Option {
+	return func(co *CacheOptions) {
+		co.grace = grace
+	}
+}
+
+// OptWithMaxTTL 设置过期时间的上限，超过上限的过期时间会被截断为maxTTL
+// 用于防止计算错误的过期时间让数据几乎永久地留在缓存中
+func OptWithMaxTTL(maxTTL time.Duration) Option {
+	return func(co *CacheOptions) {
+		co.maxTTL = maxTTL
+	}
+}
+
+// OptWithTTLJitter 写入时让每个key的过期时间在±fraction的比例内随机浮动，例如0.1表示±10%
+// 用于避免同时写入的大量key在同一时刻过期，造成集中的清理和重新加载；fraction取值范围为[0, 1]，超出时截断
+func OptWithTTLJitter(fraction float64) Option {
+	return func(co *CacheOptions) {
+		co.jitter = fraction
+	}
+}
+
+// OptWithMissTracking 开启key连续未命中次数的记录，最多记录maxKeys个key，可用于判断是否需要预取
+func OptWithMissTracking(maxKeys int) Option {
+	return func(co *CacheOptions) {
+		co.missTrack = maxKeys
+	}
+}
+
+// OptWithLoaderRetry 设置GetOrCompute加载失败时的重试，最多尝试attempts次，第attempt次失败后等待backoff(attempt)
+// 全部尝试都失败后，在backoff(attempts)时间内对同一个key的加载直接返回上次的错误，避免反复请求后端
+func OptWithLoaderRetry(attempts int, backoff func(attempt int) time.Duration) Option {
+	return func(co *CacheOptions) {
+		co.retryAttempts = attempts
+		co.retryBackoff = backoff
+	}
+}
+
+// OptWithWeakValues 因超出容量被淘汰的value保留弱引用，GC回收之前Get仍然可以找回并重新写入缓存，计为一次SoftHits
+// 需要go1.24及以上版本，低于go1.24时不生效
+func OptWithWeakValues(enable bool) Option {
+	return func(co *CacheOptions) {
+		co.weakValues = enable && weakSupported
+	}
+}
+
+// OptWithEvictBatch 设置超出key数量上限时一次淘汰的key数量
+// 一次淘汰n个key，使key数量降到max-n+1，避免在上限附近每次Set都触发淘汰，代价是实际可用的容量略小
+func OptWithEvictBatch(n int) Option {
+	return func(co *CacheOptions) {
+		co.evictN = n
+	}
+}
+
+// OptWithUpdateBuffer 设置lru更新channel的缓冲大小，默认为5
+// channel已满时Get不会阻塞，而是放弃本次lru刷新，Set、Del等写入会暂存到asyncJob稍后处理；
+// 调大缓冲可以减少asyncJob繁忙时被丢弃的刷新，代价是积压的消息会更久地持有已删除的节点
+func OptWithUpdateBuffer(size int) Option {
+	return func(co *CacheOptions) {
+		if size > 0 {
+			co.updateBuffer = size
+		}
+	}
+}
+
+// OptWithCleanupInterval 设置定时清理过期key的间隔，默认为50ms
+// 间隔越长，空闲时唤醒asyncJob的次数越少，但过期的key也会在缓存中多保留最多一个间隔；不大于0时使用默认值
+func OptWithCleanupInterval(d time.Duration) Option {
 	return func(co *CacheOptions) {
-		if strings.HasSuffix(maxMemory, "GB") {
-			maxMemory = strings.TrimSuffix(maxMemory, "GB")
-			n, _ := strconv.Atoi(maxMemory)
-			co.maxMemory = n * 1024 * 1024 * 1024
-		} else if strings.HasSuffix(maxMemory, "MB") {
-			maxMemory = strings.TrimSuffix(maxMemory, "MB")
-			n, _ := strconv.Atoi(maxMemory)
-			co.maxMemory = n * 1024 * 1024
-		} else if strings.HasSuffix(maxMemory, "KB") {
-			maxMemory = strings.TrimSuffix(maxMemory, "KB")
-			n, _ := strconv.Atoi(maxMemory)
-			co.maxMemory = n * 1024
+		if d > 0 {
+			co.cleanup = d
 		}
 	}
 }
 
+// OptWithSelfHeal 设置定时清理时是否检查并修复map和lru链表的不一致
+func OptWithSelfHeal(selfHeal bool) Option {
+	return func(co *CacheOptions) {
+		co.selfHeal = selfHeal
+	}
+}
+
+// OptWithOnUnreadExpire 设置key从未被读取就过期时的回调，可以用来发现无效的缓存写入
+func OptWithOnUnreadExpire[K comparable, V any](fn func(key K, value *V)) Option {
+	return func(co *CacheOptions) {
+		co.onUnreadExpire = fn
+	}
+}
+
+// OptWithValidator 设置写入前的value校验函数，校验不通过的value不会被缓存
+func OptWithValidator[V any](fn func(value *V) error) Option {
+	return func(co *CacheOptions) {
+		co.validator = fn
+	}
+}
+
+// OptWithOnRemove 设置key被删除、过期或淘汰时的回调，可以用来释放key关联的外部资源
+// 回调在锁外执行
+func OptWithOnRemove[K comparable, V any](fn func(key K, value *V)) Option {
+	return func(co *CacheOptions) {
+		co.onRemove = fn
+	}
+}
+
+// OptWithKeyStringer 设置诊断信息中key的格式化方式，默认使用fmt.Sprintf("%v", key)
+// 适用于结构体等直接格式化后不易阅读的key
+func OptWithKeyStringer[K comparable](fn func(key K) string) Option {
+	return func(co *CacheOptions) {
+		co.keyStringer = fn
+	}
+}
+
+// OptWithKeyTransform 设置读写前对key的转换，例如为多租户的key加上租户前缀
+// 转换对所有读写key的方法生效，Pairs、StaleKeys等枚举方法返回的是转换后的key
+func OptWithKeyTransform[K comparable](fn func(key K) K) Option {
+	return func(co *CacheOptions) {
+		co.keyTransform = fn
+	}
+}
+
+// OptWithKeyPrefix 为字符串类型的key加上统一的前缀
+func OptWithKeyPrefix[K ~string](prefix K) Option {
+	return OptWithKeyTransform(func(key K) K {
+		return prefix + key
+	})
+}
+
+// OptWithSizeEstimator 设置估算value占用内存的函数，返回值应包括每个key固定的额外开销
+// 未设置时，如果设置了OptWithMaxMemory或OptWithMaxEntrySize，则使用DefaultReflectSizer估算
+func OptWithSizeEstimator[V any](fn func(value *V) int) Option {
+	return func(co *CacheOptions) {
+		co.sizer = fn
+	}
+}
+
+// OptWithSizer 设置估算value本身占用内存的函数，每个key固定的额外开销按lru节点等结构的大小自动计入
+// 同时设置了OptWithSizeEstimator时以OptWithSizeEstimator为准
+func OptWithSizer[V any](fn func(value *V) int) Option {
+	return func(co *CacheOptions) {
+		co.valueSizer = fn
+	}
+}
+
+// NewCache 创建缓存，选项有误时panic
 func NewCache[K comparable, V any](opts ...Option) *LCache[K, V] {
-	o := &CacheOptions{}
+	lc, err := NewCacheWithError[K, V](opts...)
+	if err != nil {
+		panic(err)
+	}
+	return lc
+}
+
+// NewCacheWithError 创建缓存，选项有误时返回错误
+func NewCacheWithError[K comparable, V any](opts ...Option) (*LCache[K, V], error) {
+	o, err := newOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return build[K, V](o), nil
+}
+
+// newOptions 在默认值上依次应用选项，返回选项中的错误
+func newOptions(opts ...Option) (*CacheOptions, error) {
+	o := &CacheOptions{updateBuffer: chanSize, cleanup: cleanupInterval}
 	for _, opt := range opts {
 		opt(o)
 	}
+	return o, o.err
+}
+
+// build 按选项创建缓存，设置了OptWithShards时创建分片缓存
+func build[K comparable, V any](o *CacheOptions) *LCache[K, V] {
+	if o.shards > 1 {
+		return newShardedCache[K, V](o)
+	}
+	return newCache[K, V](o)
+}
 
+// newCache 创建一个分片，启动它的asyncJob
+func newCache[K comparable, V any](o *CacheOptions) *LCache[K, V] {
 	lc := &LCache[K, V]{}
-	lc.o = *o
+	lc.init(o)
 	lc.kvStore = make(map[K]*lruNode[K, V])
-	lc.ch = make(chan *lruNode[K, V], 5)
+	lc.dependents = make(map[K]map[K]struct{})
+	lc.missCounts = make(map[K]int)
+	lc.ghosts = make(map[K]weakRef[V])
+	lc.ch = make(chan *lruNode[K, V], o.updateBuffer)
+	lc.jobCh = make(chan func())
+	lc.exited = make(chan struct{})
 	lc.lruHead = &lruNode[K, V]{}
 	lc.lruTail = &lruNode[K, V]{}
 	lc.lruHead.next = lc.lruTail
 	lc.lruTail.prev = lc.lruHead
+	lc.expq = lc.newExpiryQueue()
 
 	go lc.asyncJob()
 
 	return lc
 }
 
-// Set 设置/更新缓存内容
+// init 按选项设置回调以及加载相关的状态，分片和负责路由的缓存都需要
+func (lc *LCache[K, V]) init(o *CacheOptions) {
+	lc.o = *o
+	lc.o.exp = lc.clampTTL(o.exp)
+	lc.onUnreadExpire, _ = o.onUnreadExpire.(func(K, *V))
+	lc.validator, _ = o.validator.(func(*V) error)
+	lc.onRemove, _ = o.onRemove.(func(K, *V))
+	lc.onEvict, _ = o.onEvict.(func(K, *V, EvictReason))
+	lc.keyStringer, _ = o.keyStringer.(func(K) string)
+	lc.keyTransform, _ = o.keyTransform.(func(K) K)
+	lc.sizer, _ = o.sizer.(func(*V) int)
+	if valueSizer, ok := o.valueSizer.(func(*V) int); ok && lc.sizer == nil {
+		lc.sizer = func(value *V) int {
+			return defaultEntryOverhead + valueSizer(value)
+		}
+	}
+	if lc.sizer == nil && (o.maxMemory > 0 || o.maxEntrySize > 0) {
+		lc.sizer = func(value *V) int {
+			return DefaultReflectSizer.Size(value)
+		}
+	}
+	lc.inflight = make(map[K]*call[V])
+	lc.failures = make(map[K]loadFailure)
+}
+
+// optWithListExpiry 使用lru表尾扫描代替按过期时刻排序的最小堆查找过期节点
+func optWithListExpiry() Option {
+	return func(co *CacheOptions) {
+		co.listScan = true
+	}
+}
+
+// newExpiryQueue 按选项创建过期队列
+// 默认使用最小堆：lru链表按访问顺序排列，Get刷新、SetWithTTL等都会让它与过期顺序不一致，
+// 堆与lru顺序无关，总能在O(log n)内找到最早过期的节点
+func (lc *LCache[K, V]) newExpiryQueue() expiryQueue[K, V] {
+	if lc.o.listScan {
+		return &listExpiryQueue[K, V]{lc: lc}
+	}
+	return &heapExpiryQueue[K, V]{}
+}
+
+// storageKey 按OptWithKeyTransform转换key，所有读写key的方法都在入口处转换
+func (lc *LCache[K, V]) storageKey(key K) K {
+	if lc.keyTransform != nil {
+		return lc.keyTransform(key)
+	}
+	return key
+}
+
+// ErrNilValue 写入的value为nil。nil不会被写入缓存，需要记录key在数据源中不存在时使用SetNegative
+var ErrNilValue = errors.New("localcache: nil value")
+
+// Set 设置/更新缓存内容，value为nil时不写入，已有的value保持不变
 func (lc *LCache[K, V]) Set(key K, value *V) {
-	lc.lock.Lock()
-	defer lc.lock.Unlock()
+	_ = lc.set(key, value, nil)
+}
+
+// SetVal 以值的方式设置/更新缓存内容，缓存内部保存value的副本
+// 调用方不需要自己取地址，不会因为传入同一个变量的地址而让多个key共享一个value
+func (lc *LCache[K, V]) SetVal(key K, value V) {
+	lc.Set(key, &value)
+}
+
+// TrySet 设置/更新缓存内容，value为nil时返回ErrNilValue，未通过校验时返回校验错误，缓存已经Seal时返回ErrSealed
+func (lc *LCache[K, V]) TrySet(key K, value *V) error {
+	return lc.set(key, value, nil)
+}
+
+// SetWithTTL 设置/更新缓存内容，并为该key单独指定过期时间
+func (lc *LCache[K, V]) SetWithTTL(key K, value *V, ttl time.Duration) {
+	_ = lc.set(key, value, func(n *lruNode[K, V]) {
+		n.exp.Store(ttl)
+	})
+}
+
+// SetWithExpireCallback 设置/更新缓存内容，为该key单独指定过期时间以及过期时的回调
+// 回调只在key过期被清理时由asyncJob调用一次，在锁外执行；key被覆盖写入后回调被清除，被删除或淘汰时不会调用
+func (lc *LCache[K, V]) SetWithExpireCallback(key K, value *V, ttl time.Duration, onExpire func(key K, value *V)) {
+	_ = lc.set(key, value, func(n *lruNode[K, V]) {
+		n.exp.Store(ttl)
+		n.onExpire = onExpire
+	})
+}
+
+// SetWithPriority 设置/更新缓存内容，并指定淘汰优先级
+// 超出容量时，lru表尾附近优先级低的key会先被淘汰
+func (lc *LCache[K, V]) SetWithPriority(key K, value *V, priority int) {
+	_ = lc.set(key, value, func(n *lruNode[K, V]) {
+		n.prio = priority
+	})
+}
+
+// SetWithDeps 设置/更新缓存内容，并声明该key依赖的其他key
+// 被依赖的key删除或过期时，所有直接或间接依赖它的key都会一起失效；因为容量被淘汰时不会级联，依赖它的key仍然有效
+func (lc *LCache[K, V]) SetWithDeps(key K, value *V, dependsOn ...K) {
+	_ = lc.set(key, value, func(n *lruNode[K, V]) {
+		n.deps = make([]K, len(dependsOn))
+		for i, d := range dependsOn {
+			n.deps[i] = lc.storageKey(d)
+		}
+	})
+}
+
+// set 写入key，节点的属性先恢复为默认值，再由update按需修改
+func (lc *LCache[K, V]) set(key K, value *V, update func(n *lruNode[K, V])) error {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.set(key, value, update)
+	}
+	if err := lc.validate(value); err != nil {
+		return err
+	}
+
+	key = lc.storageKey(key)
+
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return ErrSealed
+	}
+	old, replaced := lc.store(key, value, update)
+	lc.lock.Unlock()
+
+	if replaced {
+		lc.evict(old)
+	}
+	return nil
+}
+
+// dropNode 将n从map中删除，调用方需持有写锁
+func (lc *LCache[K, V]) dropNode(n *lruNode[K, V]) {
+	delete(lc.kvStore, n.k)
+	lc.stats.memory.Add(-int64(n.size))
+}
+
+// jitterTTL 按OptWithTTLJitter让过期时间随机浮动
+func (lc *LCache[K, V]) jitterTTL(ttl time.Duration) time.Duration {
+	fraction := lc.o.jitter
+	if fraction <= 0 || ttl <= 0 {
+		return ttl
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*fraction*float64(ttl))
+}
+
+// bumpVersion 为n分配新的版本号，调用方需持有写锁
+// 版本号在整个缓存(分片时为整个分片)内递增，key被删除后重新写入也不会得到用过的版本号
+func (lc *LCache[K, V]) bumpVersion(n *lruNode[K, V]) {
+	lc.versionSeq++
+	n.version = lc.versionSeq
+}
+
+// clampTTL 按OptWithMaxTTL截断过期时间
+func (lc *LCache[K, V]) clampTTL(ttl time.Duration) time.Duration {
+	if lc.o.maxTTL > 0 && ttl > lc.o.maxTTL {
+		return lc.o.maxTTL
+	}
+	return ttl
+}
+
+// store 写入key，调用方需持有写锁；key已经存在且value被替换时返回被替换的value，由调用方在锁外回调
+func (lc *LCache[K, V]) store(key K, value *V, update func(n *lruNode[K, V])) (old evicted[K, V], replaced bool) {
+	return lc.put(key, value, update, true)
+}
 
+// storeExact 与store相同，但update设置的过期时间原样使用，不再按OptWithTTLJitter浮动，用于沿用key已有的过期时间
+func (lc *LCache[K, V]) storeExact(key K, value *V, update func(n *lruNode[K, V])) (old evicted[K, V], replaced bool) {
+	return lc.put(key, value, update, false)
+}
+
+// put 实现store和storeExact，jitter表示是否让过期时间随机浮动
+func (lc *LCache[K, V]) put(key K, value *V, update func(n *lruNode[K, V]), jitter bool) (old evicted[K, V], replaced bool) {
+	if lc.closed {
+		return old, false
+	}
 	n, ok := lc.kvStore[key]
+	if ok && n.v != value {
+		old = evicted[K, V]{k: key, v: n.v, reason: ReasonReplaced}
+		if lc.expired(n, time.Now()) {
+			old.reason = ReasonExpired
+		}
+		replaced = true
+	}
 	if !ok {
 		n = &lruNode[K, V]{
-			k:   key,
-			v:   value,
-			exp: lc.o.exp,
+			k: key,
 		}
 		lc.keyCounter += 1 // 累加map历史上保存过多少个key
 	}
+	lc.unlinkDeps(n)
 	n.v = value
+	lc.bumpVersion(n)
+	n.lastSet = time.Now()
+	if !ok {
+		n.created = n.lastSet
+	}
+	if lc.sizer != nil {
+		size := lc.sizer(value)
+		lc.stats.memory.Add(int64(size - n.size))
+		n.size = size
+	}
+	n.exp.Store(lc.o.exp)
+	n.prio = 0
+	n.negative = false
+	n.onExpire = nil
+	n.persist.Store(false)
+	n.rearm.Store(true)
+	if update != nil {
+		update(n)
+	}
+	if jitter {
+		n.exp.Store(lc.clampTTL(lc.jitterTTL(n.exp.Load())))
+	}
+	lc.linkDeps(n)
+
+	if lc.o.missTrack > 0 {
+		lc.missLock.Lock()
+		delete(lc.missCounts, key)
+		lc.missLock.Unlock()
+	}
 
 	lc.kvStore[key] = n
+	delete(lc.ghosts, key)
+	lc.stats.sets.Add(1)
 
 	// 刷新缓存时间
-	lc.ch <- n
-}
+	lc.push(n)
 
-// Get 读取缓存内容
-func (lc *LCache[K, V]) Get(key K) (value *V, ok bool) {
-	lc.lock.RLock()
-	defer lc.lock.RUnlock()
+	return old, replaced
+}
 
-	n, ok := lc.kvStore[key]
-	if !ok {
-		return nil, false
+// GetOrSet key存在时返回已有的value和true，并像Get一样刷新它的过期时间；
+// 否则以默认的过期时间写入value，返回value和false。已过期但还没被清理的key视为不存在
+// 查找和写入在同一次写锁内完成，多个goroutine同时调用时只有一个value会被写入
+// value未通过校验或者缓存已经Seal时不会写入，key不存在时返回value和false
+func (lc *LCache[K, V]) GetOrSet(key K, value *V) (actual *V, loaded bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.GetOrSet(key, value)
+	}
+	if lc.validate(value) != nil {
+		if v, ok := lc.Get(key); ok {
+			return v, true
+		}
+		return value, false
 	}
 
-	// 刷新缓存时间
-	lc.ch <- n
+	key = lc.storageKey(key)
 
-	return n.v, true
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		if v, _, ok := lc.getSealed(key); ok {
+			return v, true
+		}
+		return value, false
+	}
+	if n, ok := lc.kvStore[key]; ok && !lc.expired(n, time.Now()) {
+		lc.countHit(true)
+		n.accessCount.Add(1)
+		// 刷新缓存时间
+		lc.push(n)
+		lc.lock.Unlock()
+		return n.v, true
+	}
+	lc.countHit(false)
+	old, replaced := lc.store(key, value, nil)
+	lc.lock.Unlock()
+
+	if replaced {
+		lc.evict(old)
+	}
+	return value, false
 }
 
-// Del 读取缓存内容
-func (lc *LCache[K, V]) Del(key K) {
-	lc.lock.Lock()
-	defer lc.lock.Unlock()
+// GetOrSetWithTTL key存在时返回已有的value和true，不改变它的过期时间；
+// 否则以ttl为过期时间写入value，返回value和false。已过期但还没被清理的key视为不存在
+// 适用于以ttl作为租期的锁等只有第一个写入者生效的场景；命中统计与GetOrSet相同
+// value未通过校验或者缓存已经Seal时不会写入，key不存在时返回value和false
+func (lc *LCache[K, V]) GetOrSetWithTTL(key K, value *V, ttl time.Duration) (actual *V, loaded bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.GetOrSetWithTTL(key, value, ttl)
+	}
+	valid := lc.validate(value) == nil
 
-	n, ok := lc.kvStore[key]
-	if !ok {
-		return
+	key = lc.storageKey(key)
+
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		if v, _, ok := lc.getSealed(key); ok {
+			return v, true
+		}
+		return value, false
+	}
+	if n, ok := lc.kvStore[key]; ok && !lc.expired(n, time.Now()) {
+		lc.countHit(true)
+		n.accessCount.Add(1)
+		lc.lock.Unlock()
+		return n.v, true
 	}
-	n.rmFlag = true
-	delete(lc.kvStore, key)
+	lc.countHit(false)
+	if !valid {
+		lc.lock.Unlock()
+		return value, false
+	}
+	old, replaced := lc.store(key, value, func(n *lruNode[K, V]) {
+		n.exp.Store(ttl)
+	})
+	lc.lock.Unlock()
 
-	// 刷新缓存时间
-	lc.ch <- n
+	if replaced {
+		lc.evict(old)
+	}
+	return value, false
 }
 
-//----
+// Replace 只在key存在时更新value，返回key是否存在；key不存在时不会写入。已过期但还没被清理的key视为不存在
+// 更新时的行为与Set一致，过期时间恢复为默认值并重新计时
+// value未通过校验或者缓存已经Seal时不会更新，返回false
+func (lc *LCache[K, V]) Replace(key K, value *V) bool {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.Replace(key, value)
+	}
+	if lc.validate(value) != nil {
+		return false
+	}
 
-// asyncJob 处理lru的更新，以及定时清理过期的缓存内容
-func (lc *LCache[K, V]) asyncJob() {
-	t := time.NewTicker(time.Millisecond * 50)
-	for {
-		select {
-		case n, ok := <-lc.ch:
-			if !ok {
-				break
-			}
+	key = lc.storageKey(key)
 
-			// 更新过期时间
-			n.expAt = time.Now().Add(n.exp)
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return false
+	}
+	if n, ok := lc.kvStore[key]; !ok || lc.expired(n, time.Now()) {
+		lc.lock.Unlock()
+		return false
+	}
+	old, replaced := lc.store(key, value, nil)
+	lc.lock.Unlock()
 
-			if n.prev != nil && n.next != nil {
-				// 将n从链表中摘除
-				n.prev.next = n.next
-				n.next.prev = n.prev
-				n.prev = nil
-				n.next = nil
-			}
+	if replaced {
+		lc.evict(old)
+	}
+	return true
+}
 
-			if !n.rmFlag {
-				// 将n插入表头
-				n.prev = lc.lruHead
-				n.next = lc.lruHead.next
-				lc.lruHead.next.prev = n
-				lc.lruHead.next = n
-			}
-		case <-t.C:
-			// 清理已过期的值
-			now := time.Now()
+// Add 只在key不存在时写入value，返回是否写入；key已经存在时不覆盖也不刷新它的过期时间
+// 已过期但还没被清理的key视为不存在，查找和写入在同一次写锁内完成
+// value未通过校验或者缓存已经Seal时不会写入，返回false
+func (lc *LCache[K, V]) Add(key K, value *V) bool {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.Add(key, value)
+	}
+	if lc.validate(value) != nil {
+		return false
+	}
 
-			// 从尾部向前遍历
-			for n := lc.lruTail.prev; n != lc.lruHead; n = n.prev {
-				if now.After(n.expAt) {
-					// 将n从链表中摘除
-					n.prev.next = n.next
-					n.next.prev = n.prev
-
-					lc.lock.Lock()
-					delete(lc.kvStore, n.k)
-					lc.lock.Unlock()
-				} else {
-					// 当所有k的过期时间一致时，可以直接结束
-					break
-				}
-			}
+	key = lc.storageKey(key)
 
-			// map中当前的key数量只有历史上的一半时，就清理一次map
-			if len(lc.kvStore) < lc.keyCounter/2 {
-				// 将当前map中的内容转移到新的map中
-				newMap := make(map[K]*lruNode[K, V])
-				lc.lock.RLock()
-				for k, v := range lc.kvStore {
-					newMap[k] = v
-				}
-				lc.lock.RUnlock()
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return false
+	}
+	if n, ok := lc.kvStore[key]; ok && !lc.expired(n, time.Now()) {
+		lc.lock.Unlock()
+		return false
+	}
+	old, replaced := lc.store(key, value, nil)
+	lc.lock.Unlock()
 
-				// 替换掉老的map
-				lc.lock.Lock()
-				lc.kvStore = newMap
-				lc.keyCounter = len(lc.kvStore)
-				lc.lock.Unlock()
-			}
-		}
+	if replaced {
+		lc.evict(old)
 	}
+	return true
+}
+
+// SetIfExpired 只在key不存在或者已经过期时写入value，返回是否写入；未过期的key保持不变，也不刷新过期时间
+// 适用于提前刷新的场景，避免重复加载仍然新鲜的key。判断和写入在同一次写锁内完成，行为与Add相同
+func (lc *LCache[K, V]) SetIfExpired(key K, value *V) bool {
+	return lc.Add(key, value)
+}
+
+// Get 读取缓存内容
+func (lc *LCache[K, V]) Get(key K) (value *V, ok bool) {
+	return lc.get(key, nil)
+}
+
+// GetVal 以值的方式读取缓存内容，返回value的副本，key不存在时返回V的零值和false
+func (lc *LCache[K, V]) GetVal(key K) (value V, ok bool) {
+	v, ok := lc.Get(key)
+	if !ok || v == nil {
+		return value, false
+	}
+	return *v, true
+}
+
+// Peek 读取缓存内容，不刷新过期时间，也不改变在lru链表中的位置；已经过期的key视为不存在
+func (lc *LCache[K, V]) Peek(key K) (value *V, ok bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.Peek(key)
+	}
+	key = lc.storageKey(key)
+	if value, sealed, ok := lc.getSealed(key); sealed {
+		return value, ok
+	}
+
+	lc.rlock()
+	defer lc.lock.RUnlock()
+
+	n, ok := lc.kvStore[key]
+	if !ok || lc.expired(n, time.Now()) {
+		return nil, false
+	}
+	return n.v, true
+}
+
+// Contains 返回key是否存在且未过期，与Peek一样不刷新过期时间，也不改变在lru链表中的位置
+func (lc *LCache[K, V]) Contains(key K) bool {
+	_, ok := lc.Peek(key)
+	return ok
+}
+
+// Touch 像Get一样刷新key的过期时间以及在lru链表中的位置，但不读取value，返回key是否存在
+// 已过期但还没被清理的key视为不存在，不会被刷新；不计入命中统计
+func (lc *LCache[K, V]) Touch(key K) bool {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.Touch(key)
+	}
+	key = lc.storageKey(key)
+	if _, sealed, ok := lc.getSealed(key); sealed {
+		return ok
+	}
+
+	lc.rlock()
+	defer lc.lock.RUnlock()
+
+	n, ok := lc.kvStore[key]
+	if !ok || lc.expired(n, time.Now()) {
+		return false
+	}
+	// 刷新缓存时间
+	lc.touch(n)
+	return true
+}
+
+// TTL 返回key距离过期的剩余时间，不刷新过期时间；key不存在时返回false
+// 已经过期但还没有被清理的key返回负数；永不过期的key以及缓存已经Seal时返回0
+// 刚写入、还没有被asyncJob计算过期时刻的key返回它的完整过期时间
+func (lc *LCache[K, V]) TTL(key K) (time.Duration, bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.TTL(key)
+	}
+	key = lc.storageKey(key)
+	if _, sealed, ok := lc.getSealed(key); sealed {
+		return 0, ok
+	}
+
+	lc.rlock()
+	defer lc.lock.RUnlock()
+
+	n, ok := lc.kvStore[key]
+	if !ok {
+		return 0, false
+	}
+	if n.persist.Load() {
+		return 0, true
+	}
+	expAt := n.expAt.Load()
+	if expAt.IsZero() {
+		return n.exp.Load(), true
+	}
+	return time.Until(expAt), true
+}
+
+// GetEntry 读取缓存内容以及元数据，与Get一样会刷新过期时间，返回的ExpAt和Remaining是刷新之后的值
+// 使用OptWithFixedExpire时不刷新过期时间，返回写入时确定的过期时刻
+// 返回的Key为调用方传入的key；缓存已经Seal或value通过弱引用找回时，只有Key和Value有效
+func (lc *LCache[K, V]) GetEntry(key K) (Entry[K, V], bool) {
+	var e Entry[K, V]
+	value, ok := lc.get(key, func(n *lruNode[K, V]) {
+		now := time.Now()
+		e = n.entry(now)
+		if !n.persist.Load() && (!lc.o.fixedExp || n.rearm.Load() || n.expAt.IsZero()) {
+			e.ExpAt = now.Add(n.exp.Load())
+			e.Remaining = n.exp.Load()
+		}
+	})
+	if !ok {
+		return Entry[K, V]{}, false
+	}
+	e.Key = key
+	e.Value = value
+	return e, true
+}
+
+// GetWithTTL 读取缓存内容以及距离过期的剩余时间，与Get一样会刷新过期时间，返回的是刷新之后的剩余时间
+// 使用OptWithFixedExpire时不刷新过期时间，剩余时间随调用逐渐减少
+// 永不过期的key、缓存已经Seal或value通过弱引用找回时剩余时间为0
+func (lc *LCache[K, V]) GetWithTTL(key K) (value *V, ttl time.Duration, ok bool) {
+	value, ok = lc.get(key, func(n *lruNode[K, V]) {
+		if n.persist.Load() {
+			return
+		}
+		if !lc.o.fixedExp || n.rearm.Load() || n.expAt.IsZero() {
+			ttl = n.exp.Load()
+			return
+		}
+		ttl = time.Until(n.expAt.Load())
+	})
+	if !ok {
+		return nil, 0, false
+	}
+	return value, ttl, true
+}
+
+// GetWithVersion 与Get一样读取缓存内容并刷新过期时间，同时返回value的版本号
+// 每次Set、Replace等写入都会让版本号递增，两次读取的版本号相同说明期间value没有被写入过；
+// 缓存已经Seal或value通过弱引用找回时版本号为0
+func (lc *LCache[K, V]) GetWithVersion(key K) (value *V, version uint64, ok bool) {
+	value, ok = lc.get(key, func(n *lruNode[K, V]) {
+		version = n.version
+	})
+	return value, version, ok
+}
+
+// get 读取缓存内容，命中时在持有读锁期间调用read
+func (lc *LCache[K, V]) get(key K, read func(n *lruNode[K, V])) (value *V, ok bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.get(key, read)
+	}
+	key = lc.storageKey(key)
+	if value, sealed, ok := lc.getSealed(key); sealed {
+		lc.countHit(ok)
+		return value, ok
+	}
+
+	lc.rlock()
+	n, ok := lc.kvStore[key]
+	if !ok {
+		lc.lock.RUnlock()
+		if value, ok := lc.resurrect(key); ok {
+			lc.countHit(true)
+			return value, true
+		}
+		lc.countHit(false)
+		lc.recordMiss(key)
+		return nil, false
+	}
+	defer lc.lock.RUnlock()
+	lc.countHit(true)
+	n.accessCount.Add(1)
+	if read != nil {
+		read(n)
+	}
+
+	// 刷新缓存时间
+	lc.touch(n)
+
+	return n.v, true
+}
+
+// countHit 累加Get命中或未命中的次数
+func (lc *LCache[K, V]) countHit(hit bool) {
+	lc.hitWindow.add(hit)
+	if hit {
+		lc.stats.hits.Add(1)
+	} else {
+		lc.stats.misses.Add(1)
+	}
+}
+
+// resurrect 通过弱引用找回因容量被淘汰、还没有被GC回收的value，并重新写入缓存
+func (lc *LCache[K, V]) resurrect(key K) (*V, bool) {
+	if !lc.o.weakValues {
+		return nil, false
+	}
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	r, ok := lc.ghosts[key]
+	if !ok {
+		return nil, false
+	}
+	delete(lc.ghosts, key)
+	value := r.value()
+	if value == nil {
+		return nil, false
+	}
+	lc.store(key, value, nil)
+	lc.stats.softHits.Add(1)
+	return value, true
+}
+
+// pruneGhosts 清理value已经被GC回收的弱引用
+func (lc *LCache[K, V]) pruneGhosts() {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	for k, r := range lc.ghosts {
+		if r.value() == nil {
+			delete(lc.ghosts, k)
+		}
+	}
+}
+
+// recordMiss 累加key连续未命中的次数
+func (lc *LCache[K, V]) recordMiss(key K) {
+	if lc.o.missTrack <= 0 {
+		return
+	}
+
+	lc.missLock.Lock()
+	defer lc.missLock.Unlock()
+
+	if _, ok := lc.missCounts[key]; !ok && len(lc.missCounts) >= lc.o.missTrack {
+		// 达到上限时随机丢弃一个key的记录
+		for k := range lc.missCounts {
+			delete(lc.missCounts, k)
+			break
+		}
+	}
+	lc.missCounts[key]++
+}
+
+// MissStreak 返回key连续未命中的次数，key被写入后清零
+// 需要通过OptWithMissTracking开启，未开启时总是返回0
+func (lc *LCache[K, V]) MissStreak(key K) int {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.MissStreak(key)
+	}
+	key = lc.storageKey(key)
+
+	lc.missLock.Lock()
+	defer lc.missLock.Unlock()
+
+	return lc.missCounts[key]
+}
+
+// Del 删除缓存内容
+func (lc *LCache[K, V]) Del(key K) {
+	removed := lc.del(key)
+	lc.notifyDeleted(removed)
+	releaseValues(removed)
+}
+
+// DelQuiet 删除缓存内容，但不触发删除回调，返回key是否存在
+// 适用于key关联的资源已经在别处释放的场景
+func (lc *LCache[K, V]) DelQuiet(key K) bool {
+	removed := lc.del(key)
+	releaseValues(removed)
+	return len(removed) > 0
+}
+
+// CompareAndDelete 只在key的当前value与old相等时删除key，返回是否删除；比较和删除在同一次写锁内完成
+// eq为nil时按指针比较；已过期但还没被清理的key视为不存在。删除时的回调与Del一致
+func (lc *LCache[K, V]) CompareAndDelete(key K, old *V, eq func(a, b *V) bool) bool {
+	removed := lc.delIf(key, func(n *lruNode[K, V]) bool {
+		if lc.expired(n, time.Now()) {
+			return false
+		}
+		if eq == nil {
+			return n.v == old
+		}
+		return eq(n.v, old)
+	})
+	if len(removed) == 0 {
+		return false
+	}
+	lc.notifyDeleted(removed)
+	releaseValues(removed)
+	return true
+}
+
+// GetAndDelete 读取key并将它删除，读取和删除在同一次写锁内完成，并发调用时只有一个调用方能取到value
+// key不存在或已过期时返回false；删除时触发OnRemove以及原因为ReasonTaken的OnEvict回调，依赖它的key与Del一样被删除
+func (lc *LCache[K, V]) GetAndDelete(key K) (value *V, ok bool) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.GetAndDelete(key)
+	}
+	removed := lc.delIf(key, func(n *lruNode[K, V]) bool {
+		return !lc.expired(n, time.Now())
+	})
+	if len(removed) == 0 {
+		lc.countHit(false)
+		return nil, false
+	}
+	lc.countHit(true)
+
+	n := removed[0]
+	value = n.v
+	if lc.onRemove != nil {
+		lc.onRemove(n.k, value)
+	}
+	lc.evict(evicted[K, V]{n.k, value, ReasonTaken})
+	lc.notifyDeleted(removed[1:])
+	releaseValues(removed)
+	return value, true
+}
+
+// releaseValues 释放已删除节点对value的引用
+// 节点可能还积压在channel中等待asyncJob处理，提前释放value可以让它尽早被回收
+func releaseValues[K comparable, V any](nodes []*lruNode[K, V]) {
+	for _, n := range nodes {
+		n.v = nil
+	}
+}
+
+// del 删除key以及依赖它的key，返回所有被删除的节点
+func (lc *LCache[K, V]) del(key K) []*lruNode[K, V] {
+	return lc.delIf(key, nil)
+}
+
+// delIf 在持有写锁期间检查key的节点，match为nil或者返回true时删除key以及依赖它的key，返回所有被删除的节点
+func (lc *LCache[K, V]) delIf(key K, match func(n *lruNode[K, V]) bool) []*lruNode[K, V] {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.delIf(key, match)
+	}
+	key = lc.storageKey(key)
+
+	lc.wlock()
+	defer lc.lock.Unlock()
+
+	if lc.sealed.Load() != nil {
+		return nil
+	}
+	n, ok := lc.kvStore[key]
+	if match != nil && (!ok || !match(n)) {
+		return nil
+	}
+	delete(lc.ghosts, key)
+	if !ok {
+		return nil
+	}
+	return lc.removeNode(n)
+}
+
+// removeNode 将n以及依赖它的节点从map中删除，并通知asyncJob摘除，调用方需持有写锁
+func (lc *LCache[K, V]) removeNode(n *lruNode[K, V]) []*lruNode[K, V] {
+	lc.unlinkDeps(n)
+	n.rmFlag.Store(true)
+	lc.dropNode(n)
+
+	removed := append([]*lruNode[K, V]{n}, lc.cascadeDeps(n.k)...)
+	for _, n := range removed {
+		// 刷新缓存时间
+		lc.push(n)
+	}
+
+	return removed
+}
+
+// Transform 在写锁内遍历所有未过期的key，用fn的结果替换value；fn返回false时删除该key
+// 不改变key的过期时间和在lru链表中的位置，删除的key会触发OnRemove回调，被替换的value触发原因为ReasonReplaced的OnEvict回调；
+// fn返回的value未通过校验(包括nil)时保留原来的value。缓存已经Seal时不做任何事
+func (lc *LCache[K, V]) Transform(fn func(key K, value *V) (*V, bool)) {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.Transform(fn)
+		}
+		return
+	}
+
+	removed, replaced := lc.transform(fn)
+	lc.notifyDeleted(removed)
+	releaseValues(removed)
+	for _, e := range replaced {
+		lc.evict(e)
+	}
+}
+
+// transform 在写锁内执行Transform的遍历，返回被删除的节点以及被替换的value
+// fn panic时同样释放写锁，已经执行的修改保留
+func (lc *LCache[K, V]) transform(fn func(key K, value *V) (*V, bool)) (removed []*lruNode[K, V], replaced []evicted[K, V]) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if lc.sealed.Load() != nil {
+		return nil, nil
+	}
+	now := time.Now()
+	for _, n := range lc.kvStore {
+		if n.rmFlag.Load() || !lc.listed(n, now) {
+			continue
+		}
+		value, keep := fn(n.k, n.v)
+		if !keep {
+			removed = append(removed, lc.removeNode(n)...)
+			continue
+		}
+		if value != n.v && lc.validate(value) == nil {
+			replaced = append(replaced, evicted[K, V]{n.k, n.v, ReasonReplaced})
+			lc.replaceValue(n, value, now)
+		}
+	}
+	return removed, replaced
+}
+
+// ResetTTL 将key的过期时间恢复为默认值，并重新计算过期时刻，返回key是否存在
+func (lc *LCache[K, V]) ResetTTL(key K) bool {
+	return lc.UpdateTTL(key, lc.o.exp)
+}
+
+// UpdateTTL 修改key的过期时间，不改变value，过期时刻按now+ttl重新计算，返回key是否存在
+// ttl同样受OptWithMaxTTL限制；永不过期的key会变为按ttl过期
+func (lc *LCache[K, V]) UpdateTTL(key K, ttl time.Duration) bool {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		return shard.UpdateTTL(key, ttl)
+	}
+	key = lc.storageKey(key)
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	n, ok := lc.kvStore[key]
+	if !ok {
+		return false
+	}
+	n.exp.Store(lc.clampTTL(ttl))
+	n.persist.Store(false)
+	n.rearm.Store(true)
+
+	// 刷新缓存时间
+	lc.push(n)
+
+	return true
+}
+
+// Len 返回未过期的key数量，已经过期但还没有被清理的key不计入
+func (lc *LCache[K, V]) Len() int {
+	if lc.shards != nil {
+		total := 0
+		for _, shard := range lc.shards {
+			total += shard.Len()
+		}
+		return total
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+	return lc.liveLen(time.Now())
+}
+
+// liveLen 返回在now时计入Len的key的数量，调用方需持有锁
+func (lc *LCache[K, V]) liveLen(now time.Time) int {
+	count := 0
+	if lc.rangeSealed(func(K, sealedEntry[K, V]) bool { count++; return true }) {
+		return count
+	}
+	for _, n := range lc.kvStore {
+		if lc.listed(n, now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Keys 返回所有未过期的key，顺序不做保证
+// 返回的是调用时的快照，之后的写入、删除和过期不会反映在结果中
+func (lc *LCache[K, V]) Keys() []K {
+	if lc.shards != nil {
+		var keys []K
+		for _, shard := range lc.shards {
+			keys = append(keys, shard.Keys()...)
+		}
+		return keys
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	keys := make([]K, 0, len(lc.kvStore))
+	if lc.rangeSealed(func(k K, _ sealedEntry[K, V]) bool { keys = append(keys, k); return true }) {
+		return keys
+	}
+	now := time.Now()
+	for k, n := range lc.kvStore {
+		if lc.listed(n, now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Range 在读锁内对每个未过期的key调用fn，fn返回false时停止遍历，顺序不做保证
+// fn执行期间持有读锁，fn中不能调用Set、Del等需要写锁的方法，否则会死锁；也不要在fn中长时间阻塞
+func (lc *LCache[K, V]) Range(fn func(key K, value *V) bool) {
+	if lc.shards != nil {
+		stopped := false
+		for _, shard := range lc.shards {
+			shard.Range(func(key K, value *V) bool {
+				stopped = !fn(key, value)
+				return !stopped
+			})
+			if stopped {
+				return
+			}
+		}
+		return
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	if lc.rangeSealed(func(k K, e sealedEntry[K, V]) bool { return fn(k, e.v) }) {
+		return
+	}
+	now := time.Now()
+	for k, n := range lc.kvStore {
+		if !lc.listed(n, now) {
+			continue
+		}
+		if !fn(k, n.v) {
+			return
+		}
+	}
+}
+
+// Pairs 返回所有未过期的key以及对应的value，keys[i]与values[i]一一对应
+// 两个切片在同一次读锁内生成，顺序不做保证
+func (lc *LCache[K, V]) Pairs() ([]K, []*V) {
+	if lc.shards != nil {
+		var (
+			keys   []K
+			values []*V
+		)
+		for _, shard := range lc.shards {
+			k, v := shard.Pairs()
+			keys = append(keys, k...)
+			values = append(values, v...)
+		}
+		return keys, values
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	keys := make([]K, 0, len(lc.kvStore))
+	values := make([]*V, 0, len(lc.kvStore))
+	if lc.rangeSealed(func(k K, e sealedEntry[K, V]) bool {
+		keys = append(keys, k)
+		values = append(values, e.v)
+		return true
+	}) {
+		return keys, values
+	}
+	now := time.Now()
+	for k, n := range lc.kvStore {
+		if !lc.listed(n, now) {
+			continue
+		}
+		keys = append(keys, k)
+		values = append(values, n.v)
+	}
+	return keys, values
+}
+
+// Snapshot 返回所有未过期的key及其value组成的新map，不包括负缓存；之后对缓存的修改不会影响返回的map
+// 设置了OptWithShards时同时持有所有分片的读锁，写入在复制期间被阻塞，得到的是同一时刻的完整视图
+func (lc *LCache[K, V]) Snapshot() map[K]*V {
+	shards := lc.shards
+	if shards == nil {
+		shards = []*LCache[K, V]{lc}
+	}
+	size := 0
+	for _, shard := range shards {
+		shard.lock.RLock()
+		size += len(shard.kvStore)
+	}
+	defer func() {
+		for _, shard := range shards {
+			shard.lock.RUnlock()
+		}
+	}()
+
+	now := time.Now()
+	snapshot := make(map[K]*V, size)
+	for _, shard := range shards {
+		if shard.rangeSealed(func(k K, e sealedEntry[K, V]) bool { snapshot[k] = e.v; return true }) {
+			continue
+		}
+		for k, n := range shard.kvStore {
+			if !lc.listed(n, now) {
+				continue
+			}
+			snapshot[k] = n.v
+		}
+	}
+	return snapshot
+}
+
+// StaleKeys 返回已经过期但还在宽限期内、尚未被清理的key，可用于在后台提前重新加载
+func (lc *LCache[K, V]) StaleKeys() []K {
+	if lc.shards != nil {
+		var keys []K
+		for _, shard := range lc.shards {
+			keys = append(keys, shard.StaleKeys()...)
+		}
+		return keys
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	now := time.Now()
+	var keys []K
+	for k, n := range lc.kvStore {
+		if lc.expired(n, now) && !n.negative {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// EntriesModifiedSince 返回最近一次写入晚于t的未过期的key，可用于增量同步
+func (lc *LCache[K, V]) EntriesModifiedSince(t time.Time) []Entry[K, V] {
+	if lc.shards != nil {
+		var entries []Entry[K, V]
+		for _, shard := range lc.shards {
+			entries = append(entries, shard.EntriesModifiedSince(t)...)
+		}
+		return entries
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+
+	var entries []Entry[K, V]
+	if lc.rangeSealed(func(k K, e sealedEntry[K, V]) bool {
+		if e.lastSet.After(t) {
+			entries = append(entries, e.entry(k))
+		}
+		return true
+	}) {
+		return entries
+	}
+	now := time.Now()
+	for _, n := range lc.kvStore {
+		if !lc.listed(n, now) || !n.lastSet.After(t) {
+			continue
+		}
+		entries = append(entries, n.entry(now))
+	}
+	return entries
+}
+
+// Stats 返回缓存的统计信息
+func (lc *LCache[K, V]) Stats() CacheStats {
+	if lc.shards != nil {
+		var stats CacheStats
+		for _, shard := range lc.shards {
+			stats.merge(shard.Stats())
+		}
+		return stats
+	}
+	return CacheStats{
+		Hits:        lc.stats.hits.Load(),
+		Misses:      lc.stats.misses.Load(),
+		Sets:        lc.stats.sets.Load(),
+		Evictions:   lc.stats.evictions.Load(),
+		Expirations: lc.stats.expirations.Load(),
+
+		UnreadExpirations: lc.stats.unreadExpirations.Load(),
+		Repairs:           lc.stats.repairs.Load(),
+		ChannelDepth:      len(lc.ch),
+		AsyncErrors:       lc.stats.asyncErrors.Load(),
+		MemoryBytes:       lc.stats.memory.Load(),
+		SoftHits:          lc.stats.softHits.Load(),
+		DroppedUpdates:    lc.stats.droppedUpdates.Load(),
+		DroppedEvents:     lc.stats.droppedEvents.Load(),
+		Rejected:          lc.stats.rejected.Load(),
+		LockWaitSamples:   lc.stats.readWait.samples.Load() + lc.stats.writeWait.samples.Load(),
+		ReadLockWaitAvg:   lc.stats.readWait.avg(),
+		ReadLockWaitMax:   time.Duration(lc.stats.readWait.max.Load()),
+		WriteLockWaitAvg:  lc.stats.writeWait.avg(),
+		WriteLockWaitMax:  time.Duration(lc.stats.writeWait.max.Load()),
+	}
+}
+
+// DefaultTTL 返回默认的过期时间，已经按OptWithMaxTTL截断
+func (lc *LCache[K, V]) DefaultTTL() time.Duration {
+	return lc.o.exp
+}
+
+// MaxKeys 返回OptWithMaxKeys设置的key数量上限，不大于0时表示不限制；设置了OptWithShards时返回整个缓存的上限
+func (lc *LCache[K, V]) MaxKeys() int {
+	return lc.o.max
+}
+
+// MaxMemory 返回OptWithMaxMemory设置的内存上限(字节)，不大于0时表示不限制；设置了OptWithShards时返回整个缓存的上限
+func (lc *LCache[K, V]) MaxMemory() int {
+	return lc.o.maxMemory
+}
+
+// ResetStats 将Stats中的各项计数以及锁等待的采样清零，不影响缓存内容，可以与其他操作并发调用
+// MemoryBytes、ChannelDepth反映的是当前状态，不会被清零；HitRate按时间窗口统计，同样不受影响
+// 各项计数逐个清零，与之并发的操作可能只有一部分计数被计入清零之后的统计
+func (lc *LCache[K, V]) ResetStats() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.ResetStats()
+		}
+		return
+	}
+	lc.stats.hits.Store(0)
+	lc.stats.misses.Store(0)
+	lc.stats.sets.Store(0)
+	lc.stats.evictions.Store(0)
+	lc.stats.expirations.Store(0)
+
+	lc.stats.unreadExpirations.Store(0)
+	lc.stats.repairs.Store(0)
+	lc.stats.asyncErrors.Store(0)
+	lc.stats.softHits.Store(0)
+	lc.stats.droppedUpdates.Store(0)
+	lc.stats.droppedEvents.Store(0)
+	lc.stats.rejected.Store(0)
+	lc.stats.readWait.reset()
+	lc.stats.writeWait.reset()
+}
+
+// Clear 清空缓存，所有key都会触发OnRemove以及原因为ReasonFlushed的OnEvict回调
+// 可以与Get/Set并发调用，Clear之前写入的key都会被清空；缓存已经Seal时不做任何事
+func (lc *LCache[K, V]) Clear() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.Clear()
+		}
+		return
+	}
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return
+	}
+	removed := make([]*lruNode[K, V], 0, len(lc.kvStore))
+	for _, n := range lc.kvStore {
+		n.rmFlag.Store(true)
+		removed = append(removed, n)
+	}
+	lc.kvStore = make(map[K]*lruNode[K, V])
+	lc.dependents = make(map[K]map[K]struct{})
+	lc.ghosts = make(map[K]weakRef[V])
+	lc.keyCounter = 0
+	lc.stats.memory.Store(0)
+	lc.lock.Unlock()
+
+	// 已经打上删除标记的节点由asyncJob从lru链表中摘除，Clear之后写入的节点不受影响
+	lc.runJob(func() {
+		for n := lc.lruHead.next; n != lc.lruTail; {
+			next := n.next
+			if n.rmFlag.Load() {
+				lc.unlinkNode(n)
+			}
+			n = next
+		}
+	})
+
+	for _, n := range removed {
+		if lc.onRemove != nil {
+			lc.onRemove(n.k, n.v)
+		}
+		lc.evict(evicted[K, V]{n.k, n.v, ReasonFlushed})
+	}
+	releaseValues(removed)
+}
+
+// resetList 摘除lru链表中的所有节点，换上新的哨兵节点和过期队列，只在asyncJob中持有写锁时调用
+// 被摘除的节点上不再残留旧链表的指针，之后channel中积压的更新不会影响新的链表
+func (lc *LCache[K, V]) resetList() {
+	for n := lc.lruHead.next; n != lc.lruTail; {
+		next := n.next
+		n.prev = nil
+		n.next = nil
+		n.heapIdx = 0
+		n.qprev = nil
+		n.qnext = nil
+		n.inProbation = false
+		n = next
+	}
+	lc.probHead = nil
+	lc.probTail = nil
+	lc.probLen = 0
+
+	lc.lruHead = &lruNode[K, V]{}
+	lc.lruTail = &lruNode[K, V]{}
+	lc.lruHead.next = lc.lruTail
+	lc.lruTail.prev = lc.lruHead
+	lc.lruLen = 0
+	lc.expq = lc.newExpiryQueue()
+}
+
+// ShardSizes 返回每个分片中的key数量，用于发现key分布不均导致的热点分片
+// 没有设置OptWithShards时只有一个分片，返回的切片长度为1
+func (lc *LCache[K, V]) ShardSizes() []int {
+	if lc.shards != nil {
+		sizes := make([]int, len(lc.shards))
+		for i, shard := range lc.shards {
+			sizes[i] = shard.ShardSizes()[0]
+		}
+		return sizes
+	}
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+	if m := lc.sealed.Load(); m != nil {
+		return []int{len(*m)}
+	}
+	return []int{len(lc.kvStore)}
+}
+
+//----
+
+// asyncJob 处理lru的更新，以及定时清理过期的缓存内容
+func (lc *LCache[K, V]) asyncJob() {
+	t := time.NewTicker(lc.o.cleanup)
+	for {
+		select {
+		case n, ok := <-lc.ch:
+			if !ok {
+				// Close之后ch中剩余的更新已经处理完
+				lc.drainPending(time.Now())
+				t.Stop()
+				close(lc.exited)
+				return
+			}
+
+			// 一次唤醒尽量多处理一些积压的更新，减少select的开销
+			now := time.Now()
+			lc.refreshNode(n, now)
+			lc.drainUpdates(now, maxDrainBatch-1)
+			lc.drainPending(now)
+
+			lc.evictOverflow()
+			lc.dispatch()
+		case fn := <-lc.jobCh:
+			lc.runStep(fn)
+		case <-t.C:
+			lc.hitWindow.roll()
+			if !lc.pausedAt.IsZero() {
+				// 过期已暂停
+				continue
+			}
+
+			// 清理已过期的值
+			lc.expire(time.Now())
+
+			if lc.o.selfHeal {
+				lc.selfHeal()
+			}
+			if lc.o.weakValues {
+				lc.pruneGhosts()
+			}
+
+			lc.compact()
+			lc.dispatch()
+		}
+	}
+}
+
+// runStep 执行runJob提交的fn，只在asyncJob中调用
+func (lc *LCache[K, V]) runStep(fn func()) {
+	// 先处理已经积压的更新，保证fn能看到调用runJob之前的所有Set/Get/Del
+	now := time.Now()
+	lc.drainUpdates(now, cap(lc.ch))
+	lc.drainPending(now)
+	lc.evictOverflow()
+	fn()
+}
+
+// DeleteExpired 立即清理已经过期的key，不必等待下一次定时清理，返回清理的key数量(不包括因依赖被一起删除的key)
+// 清理在asyncJob中进行，回调与定时清理相同；过期已经被PauseExpiry暂停时不做任何事
+func (lc *LCache[K, V]) DeleteExpired() int {
+	if lc.shards != nil {
+		expired := 0
+		for _, shard := range lc.shards {
+			expired += shard.DeleteExpired()
+		}
+		return expired
+	}
+	expired := 0
+	lc.runJob(func() {
+		if lc.pausedAt.IsZero() {
+			expired = lc.expire(time.Now())
+		}
+	})
+	return expired
+}
+
+// expire 清理在now时已经超过宽限期的过期节点，返回清理的数量，只在asyncJob中调用
+func (lc *LCache[K, V]) expire(now time.Time) (expired int) {
+	lc.drainPending(now)
+
+	// 超过宽限期的节点才会被清理
+	deadline := now.Add(-lc.o.grace)
+	for _, n := range lc.expq.PopExpired(deadline) {
+		// 过期之前有被丢弃的Get刷新，按那次读取重新计算过期时刻
+		if touched := n.touchedAt.Load(); !lc.o.fixedExp && touched.Add(n.exp.Load()).After(deadline) {
+			lc.refreshNode(n, touched)
+			continue
+		}
+		if lc.reap(n, now) {
+			expired++
+		}
+	}
+	if lc.o.maxIdle > 0 {
+		expired += lc.expireIdle(now)
+	}
+	return expired
+}
+
+// expireIdle 从lru表尾开始清理超过OptWithMaxIdle没有被访问的节点，返回清理的数量，只在asyncJob中调用
+// lru链表按移动到表头的先后排列，遇到第一个没有空闲的节点就停止
+func (lc *LCache[K, V]) expireIdle(now time.Time) (expired int) {
+	deadline := now.Add(-lc.o.maxIdle)
+	for n := lc.lruTail.prev; n != lc.lruHead; {
+		prev := n.prev
+		if n.persist.Load() {
+			n = prev
+			continue
+		}
+		// 被丢弃的Get刷新同样算作访问，补上那次刷新
+		if touched := n.touchedAt.Load(); touched.After(n.accessedAt.Load()) && touched.After(deadline) {
+			lc.refreshNode(n, touched)
+			n = prev
+			continue
+		}
+		accessed := n.accessedAt.Load()
+		if accessed.IsZero() {
+			// WarmUp、BeginRebuild等直接放到表头的节点，从现在开始计算空闲时间
+			n.accessedAt.Store(now)
+			n = prev
+			continue
+		}
+		if accessed.After(deadline) {
+			break
+		}
+		if lc.reap(n, now) {
+			expired++
+		}
+		n = prev
+	}
+	return expired
+}
+
+// reap 清理过期的节点n并触发回调，返回是否清理；n之后又被写入时只刷新它的过期时刻，只在asyncJob中调用
+func (lc *LCache[K, V]) reap(n *lruNode[K, V], now time.Time) bool {
+	lc.unlinkNode(n)
+
+	var (
+		cascaded []*lruNode[K, V]
+		onExpire func(key K, value *V)
+	)
+	lc.lock.Lock()
+	if n.rearm.Load() && lc.kvStore[n.k] == n {
+		// 过期之后又被写入或者重新设置了过期时间，对应的更新还积压在channel中
+		lc.lock.Unlock()
+		lc.refreshNode(n, now)
+		return false
+	}
+	removed := lc.kvStore[n.k] == n
+	if removed {
+		lc.unlinkDeps(n)
+		lc.dropNode(n)
+		// 依赖n的节点只打上删除标记，留在链表中等待之后的清理摘除
+		cascaded = lc.cascadeDeps(n.k)
+		onExpire = n.onExpire
+	}
+	lc.lock.Unlock()
+	if !removed {
+		return false
+	}
+	lc.notifyRemoved(ReasonDeleted, cascaded...)
+	lc.stats.expirations.Add(1)
+
+	if lc.o.logger != nil {
+		lc.logf("localcache: key %s expired", lc.keyString(n.k))
+	}
+	if n.accessCount.Load() == 0 {
+		lc.stats.unreadExpirations.Add(1)
+		if lc.onUnreadExpire != nil {
+			lc.queueCall(func() { lc.onUnreadExpire(n.k, n.v) })
+		}
+	}
+	if onExpire != nil {
+		lc.queueCall(func() { onExpire(n.k, n.v) })
+	}
+	lc.notifyRemoved(ReasonExpired, n)
+	return true
+}
+
+// compact map中当前的key数量只有历史上的一半时，就清理一次map，只在asyncJob中调用
+// 复制和替换在同一次写锁内完成，复制期间的写入不会丢失
+func (lc *LCache[K, V]) compact() {
+	lc.lock.RLock()
+	shrink := len(lc.kvStore) < lc.keyCounter/2
+	lc.lock.RUnlock()
+	if !shrink {
+		return
+	}
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	// 拿到写锁之前map可能已经被替换或者重新写满
+	if len(lc.kvStore) >= lc.keyCounter/2 {
+		return
+	}
+	// 将当前map中的内容转移到新的map中，替换掉老的map
+	newMap := make(map[K]*lruNode[K, V], len(lc.kvStore))
+	for k, v := range lc.kvStore {
+		newMap[k] = v
+	}
+	lc.kvStore = newMap
+	lc.keyCounter = len(lc.kvStore)
+}
+
+// drainUpdates 不阻塞地处理channel中积压的更新，最多处理limit条
+func (lc *LCache[K, V]) drainUpdates(now time.Time, limit int) {
+	for i := 0; i < limit; i++ {
+		select {
+		case n, ok := <-lc.ch:
+			if !ok {
+				return
+			}
+			lc.refreshNode(n, now)
+		default:
+			return
+		}
+	}
+}
+
+// runJob 在asyncJob中执行fn，并等待执行完成；asyncJob已经退出时不执行fn
+func (lc *LCache[K, V]) runJob(fn func()) {
+	done := make(chan struct{})
+	select {
+	case lc.jobCh <- func() {
+		fn()
+		// fn触发的回调执行完成之后才返回
+		lc.dispatch()
+		close(done)
+	}:
+		<-done
+	case <-lc.exited:
+	}
+}
+
+// push 通知asyncJob刷新n在lru链表中的位置，调用方需要持有写锁
+// channel已满时不阻塞，n暂存在pending中，由asyncJob在下次唤醒时处理
+func (lc *LCache[K, V]) push(n *lruNode[K, V]) {
+	if lc.closed {
+		return
+	}
+	// pending非空时继续暂存，保证更新按写入的顺序处理
+	if len(lc.pending) == 0 {
+		select {
+		case lc.ch <- n:
+			return
+		default:
+		}
+	}
+	lc.pending = append(lc.pending, n)
+	lc.hasPending.Store(true)
+}
+
+// touch 通知asyncJob将n移动到lru表头，调用方需要持有读锁
+// channel已满时直接丢弃本次刷新，只会降低lru顺序的精确度；丢弃的时刻记录在n上，n到期清理时据此延长过期时间
+func (lc *LCache[K, V]) touch(n *lruNode[K, V]) {
+	if lc.closed {
+		return
+	}
+	select {
+	case lc.ch <- n:
+	default:
+		lc.stats.droppedUpdates.Add(1)
+		if !lc.o.fixedExp || lc.o.maxIdle > 0 {
+			n.touchedAt.Store(time.Now())
+		}
+	}
+}
+
+// drainPending 处理push时因channel已满而暂存的更新，只在asyncJob中调用
+func (lc *LCache[K, V]) drainPending(now time.Time) {
+	if !lc.hasPending.Load() {
+		return
+	}
+	lc.lock.Lock()
+	pending := lc.pending
+	lc.pending = nil
+	lc.hasPending.Store(false)
+	// 此时channel中的更新都早于pending，先处理它们
+	older := len(lc.ch)
+	lc.lock.Unlock()
+
+	lc.drainUpdates(now, older)
+	for _, n := range pending {
+		lc.refreshNode(n, now)
+	}
+}
+
+// Close 停止asyncJob并等待它退出，重复调用时不做任何事
+// Close之后Get只读取map中已有的数据，Set等写入操作不再生效
+func (lc *LCache[K, V]) Close() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.Close()
+		}
+		return
+	}
+	// 所有发送都在持有lock时进行，拿到写锁说明没有正在进行的发送，之后也不会再有
+	lc.lock.Lock()
+	if lc.closed {
+		lc.lock.Unlock()
+		<-lc.exited
+		return
+	}
+	lc.closed = true
+	lc.lock.Unlock()
+
+	close(lc.ch)
+	<-lc.exited
+}
+
+// PauseExpiry 暂停过期清理，暂停期间所有key都不会过期
+// 适用于依赖的服务不可用时，宁可返回旧数据也不返回空的场景
+func (lc *LCache[K, V]) PauseExpiry() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.PauseExpiry()
+		}
+		return
+	}
+	lc.runJob(func() {
+		if lc.pausedAt.IsZero() {
+			lc.pausedAt = time.Now()
+			lc.paused.Store(true)
+		}
+	})
+}
+
+// ResumeExpiry 恢复过期清理，key的过期时刻顺延暂停期间经过的计时，避免恢复后大量key同时过期
+// 暂停期间写入或读取的key只顺延从那时到恢复的时长
+func (lc *LCache[K, V]) ResumeExpiry() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.ResumeExpiry()
+		}
+		return
+	}
+	lc.runJob(func() {
+		if lc.pausedAt.IsZero() {
+			return
+		}
+		now := time.Now()
+		paused := now.Sub(lc.pausedAt)
+		lc.pausedAt = time.Time{}
+
+		lc.lock.Lock()
+		for n := lc.lruHead.next; n != lc.lruTail; n = n.next {
+			expAt := n.expAt.Load()
+			if expAt.IsZero() {
+				continue
+			}
+			// 只顺延暂停与本次计时重叠的部分，暂停期间写入或读取的key从那时才开始计时
+			d := paused
+			if since := now.Sub(expAt.Add(-n.exp.Load())); since < d {
+				d = since
+			}
+			if d > 0 {
+				n.expAt.Store(expAt.Add(d))
+				lc.expq.update(n)
+			}
+		}
+		// 过期时刻顺延之后才恢复判断，读取方不会看到已经顺延前的过期
+		lc.paused.Store(false)
+		lc.lock.Unlock()
+	})
+}
+
+// refreshNode 更新n的过期时间，并将n移动到lru表头；n已被删除时只从链表中摘除
+func (lc *LCache[K, V]) refreshNode(n *lruNode[K, V], now time.Time) {
+	// 更新过期时间，永不过期的节点expAt保持零值；固定过期时只有写入之后才重新计算
+	if !lc.o.fixedExp || n.rearm.Load() {
+		n.expAt.Store(time.Time{})
+		if !n.persist.Load() {
+			n.expAt.Store(now.Add(n.exp.Load()))
+		}
+		n.rearm.Store(false)
+	}
+
+	if lc.o.maxIdle > 0 {
+		n.accessedAt.Store(now)
+	}
+
+	lc.unlinkNode(n)
+	if !n.rmFlag.Load() {
+		lc.linkHead(n)
+		if !n.persist.Load() {
+			lc.expq.update(n)
+		}
+	}
+}
+
+// unlinkNode 将n从lru链表以及过期队列中摘除，n不在链表中时不做任何事
+func (lc *LCache[K, V]) unlinkNode(n *lruNode[K, V]) {
+	if n.prev != nil && n.next != nil {
+		n.prev.next = n.next
+		n.next.prev = n.prev
+		n.prev = nil
+		n.next = nil
+		lc.lruLen--
+	}
+	lc.expq.remove(n)
+	if n.inProbation {
+		lc.leaveProbation(n)
+	}
+}
+
+// linkHead 将n插入lru表头
+func (lc *LCache[K, V]) linkHead(n *lruNode[K, V]) {
+	n.prev = lc.lruHead
+	n.next = lc.lruHead.next
+	lc.lruHead.next.prev = n
+	lc.lruHead.next = n
+	lc.lruLen++
+	if lc.o.policy == TwoQueue {
+		lc.admit(n)
+	}
+}
+
+// evictOverflow key数量或估算的内存占用超过上限时，淘汰lru表尾附近优先级最低的key，直到降到目标值以下
+func (lc *LCache[K, V]) evictOverflow() {
+	overKeys := lc.o.max > 0 && lc.lruLen > lc.o.max
+	if !overKeys && !lc.overMemory() {
+		return
+	}
+
+	target := lc.lruLen
+	if overKeys {
+		target = lc.o.max
+		if lc.o.evictN > 1 {
+			target = lc.o.max - lc.o.evictN + 1
+			if target < 0 {
+				target = 0
+			}
+		}
+	}
+
+	var victims []*lruNode[K, V]
+	lc.lock.Lock()
+	for lc.lruLen > target || lc.overMemory() {
+		// 从尾部向前查找优先级最低的节点，优先级相同时按淘汰策略选择，仍相同时取更靠近表尾的
+		// TwoQueue策略先从超出比例的试用队列中淘汰，主队列中查找时跳过试用队列的节点
+		var victim *lruNode[K, V]
+		if lc.o.policy == TwoQueue {
+			victim = lc.probationVictim(false)
+		}
+		if victim == nil {
+			i := 0
+			for n := lc.lruTail.prev; n != lc.lruHead && i < evictScanDepth; n = n.prev {
+				i++
+				if lc.o.policy == TwoQueue && n.inProbation {
+					continue
+				}
+				if victim == nil || lc.evictBefore(n, victim) {
+					victim = n
+				}
+			}
+		}
+		if victim == nil && lc.o.policy == TwoQueue {
+			victim = lc.probationVictim(true)
+		}
+		if victim == nil {
+			break
+		}
+
+		if lc.evictNode(victim) {
+			victims = append(victims, victim)
+		}
+	}
+	lc.lock.Unlock()
+
+	lc.stats.evictions.Add(uint64(len(victims)))
+	lc.notifyRemoved(ReasonCapacity, victims...)
+}
+
+// evictNode 将victim从lru链表和map中淘汰，只在asyncJob中持有写锁时调用
+// victim已经被删除或者被新的节点替换时只从链表中摘除，返回false
+// 淘汰只是为了腾出容量，不级联删除依赖victim的key，但victim自己登记的依赖会从反向索引中移除
+func (lc *LCache[K, V]) evictNode(victim *lruNode[K, V]) bool {
+	lc.unlinkNode(victim)
+	victim.rmFlag.Store(true)
+
+	if lc.kvStore[victim.k] != victim {
+		return false
+	}
+	lc.unlinkDeps(victim)
+	lc.dropNode(victim)
+	if lc.o.weakValues && victim.v != nil {
+		lc.ghosts[victim.k] = makeWeakRef(victim.v)
+	}
+	return true
+}
+
+// overMemory 估算的内存占用是否超出了OptWithMaxMemory设置的上限
+func (lc *LCache[K, V]) overMemory() bool {
+	return lc.o.maxMemory > 0 && lc.stats.memory.Load() > int64(lc.o.maxMemory)
+}
+
+// queueCall 登记过期、淘汰等在asyncJob中触发的用户回调，当前这一步处理完成之后由dispatch执行
+func (lc *LCache[K, V]) queueCall(fn func()) {
+	lc.notes = append(lc.notes, fn)
+}
+
+// dispatch 执行queueCall登记的回调，全部执行完成之后返回，只在asyncJob中调用
+// 回调在另一个goroutine中执行，期间asyncJob继续处理runJob提交的操作，
+// 回调中调用DeleteExpired、Prune、Clear等需要asyncJob完成的方法不会死锁
+func (lc *LCache[K, V]) dispatch() {
+	for len(lc.notes) > 0 {
+		notes := lc.notes
+		lc.notes = nil
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, fn := range notes {
+				lc.safeCall(fn)
+			}
+		}()
+		for waiting := true; waiting; {
+			select {
+			case <-done:
+				waiting = false
+			case fn := <-lc.jobCh:
+				lc.runStep(fn)
+			}
+		}
+	}
+}
+
+// safeCall 执行用户回调，回调panic时记录为错误，避免asyncJob退出
+func (lc *LCache[K, V]) safeCall(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			lc.recordError(fmt.Errorf("localcache: callback panic: %v", r))
+		}
+	}()
+	fn()
+}
+
+// recordError 记录asyncJob遇到的错误
+func (lc *LCache[K, V]) recordError(err error) {
+	lc.stats.asyncErrors.Add(1)
+	lc.lastErr.Store(&err)
+	lc.logf("%v", err)
+}
+
+// LastError 返回asyncJob最近一次遇到的错误(例如回调panic)，并清除该错误；没有错误时返回nil
+func (lc *LCache[K, V]) LastError() error {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			if err := shard.LastError(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if p := lc.lastErr.Swap(nil); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// selfHeal 修复map和lru链表之间的不一致：
+// 在map中但不在链表中的节点重新插入表头，在链表中但已不在map中的节点从链表中摘除
+func (lc *LCache[K, V]) selfHeal() {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	for n := lc.lruTail.prev; n != lc.lruHead; {
+		prev := n.prev
+		// 带删除标记的节点会在之后的处理中摘除，不属于不一致
+		if !n.rmFlag.Load() && lc.kvStore[n.k] != n {
+			lc.unlinkNode(n)
+			n.rmFlag.Store(true)
+			lc.stats.repairs.Add(1)
+		}
+		n = prev
+	}
+
+	for _, n := range lc.kvStore {
+		// expAt为零值的节点还在等待asyncJob第一次处理，不属于不一致
+		if n.prev == nil && n.next == nil && !n.expAt.IsZero() {
+			lc.linkHead(n)
+			lc.expq.update(n)
+			lc.stats.repairs.Add(1)
+		}
+	}
+}
+
+// linkDeps 将n登记到其依赖key的反向索引中，调用方需持有写锁
+func (lc *LCache[K, V]) linkDeps(n *lruNode[K, V]) {
+	for _, d := range n.deps {
+		s, ok := lc.dependents[d]
+		if !ok {
+			s = make(map[K]struct{})
+			lc.dependents[d] = s
+		}
+		s[n.k] = struct{}{}
+	}
+}
+
+// unlinkDeps 将n从其依赖key的反向索引中移除，调用方需持有写锁
+func (lc *LCache[K, V]) unlinkDeps(n *lruNode[K, V]) {
+	for _, d := range n.deps {
+		if s, ok := lc.dependents[d]; ok {
+			delete(s, n.k)
+			if len(s) == 0 {
+				delete(lc.dependents, d)
+			}
+		}
+	}
+	n.deps = nil
+}
+
+// cascadeDeps 删除所有直接或间接依赖key的节点，返回被删除的节点，调用方需持有写锁
+// 通过visited记录已经处理过的key，依赖关系中存在环时也能正常结束
+func (lc *LCache[K, V]) cascadeDeps(key K) []*lruNode[K, V] {
+	var removed []*lruNode[K, V]
+	visited := map[K]struct{}{key: {}}
+	queue := []K{key}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+
+		for dk := range lc.dependents[k] {
+			if _, ok := visited[dk]; ok {
+				continue
+			}
+			visited[dk] = struct{}{}
+			queue = append(queue, dk)
+
+			if n, ok := lc.kvStore[dk]; ok {
+				lc.unlinkDeps(n)
+				n.rmFlag.Store(true)
+				lc.dropNode(n)
+				removed = append(removed, n)
+			}
+		}
+		delete(lc.dependents, k)
+	}
+	return removed
+}
+
+// keyString 按OptWithKeyStringer设置的方式格式化key
+func (lc *LCache[K, V]) keyString(key K) string {
+	if lc.keyStringer != nil {
+		return lc.keyStringer(key)
+	}
+	return fmt.Sprintf("%v", key)
+}
+
+// DebugString 返回缓存的诊断信息，包括key的数量以及从lru表头到表尾的key
+func (lc *LCache[K, V]) DebugString() string {
+	if lc.shards != nil {
+		parts := make([]string, len(lc.shards))
+		for i, shard := range lc.shards {
+			parts[i] = fmt.Sprintf("shard%d: %s", i, shard.DebugString())
+		}
+		return strings.Join(parts, "\n")
+	}
+	var sb strings.Builder
+	lc.runJob(func() {
+		fmt.Fprintf(&sb, "len=%d lru=[", lc.lruLen)
+		for n := lc.lruHead.next; n != lc.lruTail; n = n.next {
+			if n != lc.lruHead.next {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(lc.keyString(n.k))
+		}
+		sb.WriteByte(']')
+	})
+	return sb.String()
 }
 
+// dumpLink 通过Logger输出lru链表，只在asyncJob中调用
 func (lc *LCache[K, V]) dumpLink() {
-	fmt.Println("dumpLink:")
+	lc.logf("dumpLink:")
 	// 从尾部向前遍历
 	for n := lc.lruTail; n != nil; n = n.prev {
 		if n.next == nil {
-			fmt.Printf("tail %p\n", &*n)
+			lc.logf("tail %p", &*n)
 		} else if n.prev == nil {
-			fmt.Printf("head %p\n", &*n)
+			lc.logf("head %p", &*n)
 		} else {
-			fmt.Println("node", "key", n.k, "next", &*n.next, "prev", &*n.prev)
+			lc.logf("node key %s next %p prev %p", lc.keyString(n.k), &*n.next, &*n.prev)
 		}
 	}
 }
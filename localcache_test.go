@@ -1,8 +1,14 @@
 package localcache
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -326,3 +332,1994 @@ func TestLCache_CleanMap(t *testing.T) {
 		})
 	}
 }
+
+func TestLCache_ResetTTL(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 500))
+
+	n := 1
+	lc.SetWithTTL("a", &n, time.Millisecond*100)
+
+	if ok := lc.ResetTTL("a"); !ok {
+		t.Errorf("ResetTTL() ok = %v, want %v", ok, true)
+	}
+	if ok := lc.ResetTTL("b"); ok {
+		t.Errorf("ResetTTL() ok = %v, want %v", ok, false)
+	}
+
+	// 超过原先的短过期时间后，key应该仍然存在
+	time.Sleep(time.Millisecond * 250)
+	if _, ok := lc.Get("a"); !ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, true)
+	}
+}
+
+func TestLCache_Priority(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithMaxKeys(3))
+
+	high, low := 1, 2
+	lc.SetWithPriority("h1", &high, 10)
+	lc.SetWithPriority("h2", &high, 10)
+	lc.Set("l1", &low)
+	lc.Set("l2", &low)
+	time.Sleep(time.Millisecond * 50)
+
+	// h1、h2虽然更靠近lru表尾，但优先级高，应该先淘汰l1
+	tests := []struct {
+		key    string
+		wantOk bool
+	}{
+		{"h1", true},
+		{"h2", true},
+		{"l1", false},
+		{"l2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, gotOk := lc.Get(tt.key); gotOk != tt.wantOk {
+				t.Errorf("Get() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestLCache_MaxKeys(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithMaxKeys(3))
+
+	for i, k := range []string{"a", "b", "c", "d"} {
+		n := i
+		lc.Set(k, &n)
+	}
+	// 等待asyncJob处理完积压的更新并完成淘汰
+	lc.DebugString()
+
+	if got := lc.Len(); got != 3 {
+		t.Errorf("Len() = %v, want 3", got)
+	}
+	tests := []struct {
+		key    string
+		wantOk bool
+	}{
+		{"a", false},
+		{"b", true},
+		{"c", true},
+		{"d", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, gotOk := lc.Get(tt.key); gotOk != tt.wantOk {
+				t.Errorf("Get() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestLCache_MaxMemory(t *testing.T) {
+	const valueSize = 100
+	lc := NewCache[int, int](OptWithExpire(time.Second), OptWithMaxMemory("2KB"), OptWithSizer(func(*int) int {
+		return valueSize
+	}))
+
+	fit := 2 * 1024 / (valueSize + defaultEntryOverhead)
+	for i := 0; i < fit+3; i++ {
+		n := i
+		lc.Set(i, &n)
+	}
+	lc.DebugString()
+
+	if got := lc.Len(); got != fit {
+		t.Errorf("Len() = %v, want %v", got, fit)
+	}
+	if got, want := lc.Stats().MemoryBytes, int64(fit*(valueSize+defaultEntryOverhead)); got != want {
+		t.Errorf("Stats().MemoryBytes = %v, want %v", got, want)
+	}
+	// 最早写入的key被淘汰
+	for i := 0; i < 3; i++ {
+		if _, ok := lc.Get(i); ok {
+			t.Errorf("Get(%v) gotOk = %v, want %v", i, ok, false)
+		}
+	}
+	if _, ok := lc.Get(fit + 2); !ok {
+		t.Errorf("Get(%v) gotOk = %v, want %v", fit+2, ok, true)
+	}
+}
+
+func TestLCache_BatchDrainOrder(t *testing.T) {
+	// 缓冲足够容纳所有更新，避免Get的刷新因channel已满被丢弃
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithUpdateBuffer(16))
+
+	for i, k := range []string{"a", "b", "c", "d", "e"} {
+		n := i
+		lc.Set(k, &n)
+	}
+	lc.Get("a")
+	lc.Get("c")
+	time.Sleep(time.Millisecond * 20)
+
+	var got []string
+	lc.runJob(func() {
+		for n := lc.lruHead.next; n != lc.lruTail; n = n.next {
+			got = append(got, n.k)
+		}
+	})
+	want := []string{"c", "a", "e", "d", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lru order = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkLCache_Get(b *testing.B) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+	for i := 0; i < 1024; i++ {
+		n := i
+		lc.Set(i, &n)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			lc.Get(i & 1023)
+			i++
+		}
+	})
+}
+
+func TestLCache_UnreadExpirations(t *testing.T) {
+	var unread []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Millisecond*100),
+		OptWithOnUnreadExpire(func(key string, value *int) {
+			unread = append(unread, key)
+		}),
+	)
+
+	for i, k := range []string{"a", "b", "c", "d"} {
+		n := i
+		lc.Set(k, &n)
+	}
+	lc.Get("a")
+	lc.Get("c")
+	time.Sleep(time.Millisecond * 300)
+	// 回调在asyncJob中执行，Close等待asyncJob退出之后再读取unread
+	lc.Close()
+
+	if got := lc.Stats().UnreadExpirations; got != 2 {
+		t.Errorf("Stats() UnreadExpirations = %v, want %v", got, 2)
+	}
+	sort.Strings(unread)
+	if want := []string{"b", "d"}; !reflect.DeepEqual(unread, want) {
+		t.Errorf("OnUnreadExpire keys = %v, want %v", unread, want)
+	}
+}
+
+func TestLCache_Validator(t *testing.T) {
+	errEmpty := errors.New("empty value")
+	lc := NewCache[string, string](
+		OptWithExpire(time.Second),
+		OptWithValidator(func(value *string) error {
+			if value == nil || *value == "" {
+				return errEmpty
+			}
+			return nil
+		}),
+	)
+
+	empty, valid := "", "v"
+	tests := []struct {
+		name    string
+		key     string
+		value   *string
+		wantErr error
+	}{
+		{"nil", "a", nil, ErrNilValue},
+		{"empty", "b", &empty, errEmpty},
+		{"valid", "c", &valid, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := lc.TrySet(tt.key, tt.value); err != tt.wantErr {
+				t.Errorf("TrySet() err = %v, want %v", err, tt.wantErr)
+			}
+			if _, gotOk := lc.Get(tt.key); gotOk != (tt.wantErr == nil) {
+				t.Errorf("Get() gotOk = %v, want %v", gotOk, tt.wantErr == nil)
+			}
+		})
+	}
+
+	// Set同样会拒绝未通过校验的value
+	lc.Set("d", &empty)
+	if _, ok := lc.Get("d"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_SetNil(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	// nil不会被写入，key不存在时仍然不存在
+	lc.Set("a", nil)
+	if v, ok := lc.Get("a"); ok {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, nil, false)
+	}
+	if err := lc.TrySet("a", nil); err != ErrNilValue {
+		t.Errorf("TrySet() err = %v, want %v", err, ErrNilValue)
+	}
+
+	// 已有的value不会被nil覆盖
+	lc.SetVal("b", 1)
+	lc.Set("b", nil)
+	if v, ok := lc.Get("b"); !ok || v == nil || *v != 1 {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+	if got := lc.Len(); got != 1 {
+		t.Errorf("Len() = %v, want %v", got, 1)
+	}
+}
+
+func TestLCache_DelQuiet(t *testing.T) {
+	var removed []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Second),
+		OptWithOnRemove(func(key string, value *int) {
+			removed = append(removed, key)
+		}),
+	)
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+
+	lc.Del("a")
+	if ok := lc.DelQuiet("b"); !ok {
+		t.Errorf("DelQuiet() ok = %v, want %v", ok, true)
+	}
+	if ok := lc.DelQuiet("b"); ok {
+		t.Errorf("DelQuiet() ok = %v, want %v", ok, false)
+	}
+
+	if want := []string{"a"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("OnRemove keys = %v, want %v", removed, want)
+	}
+	if _, ok := lc.Get("b"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_Deps(t *testing.T) {
+	var removed []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Second),
+		OptWithOnRemove(func(key string, value *int) {
+			removed = append(removed, key)
+		}),
+	)
+
+	n := 1
+	lc.Set("a", &n)
+	lc.SetWithDeps("b", &n, "a")
+	lc.SetWithDeps("c", &n, "b")
+	lc.Set("d", &n)
+	// x、y互相依赖
+	lc.SetWithDeps("x", &n, "y")
+	lc.SetWithDeps("y", &n, "x")
+
+	lc.Del("a")
+	lc.Del("x")
+
+	tests := []struct {
+		key    string
+		wantOk bool
+	}{
+		{"a", false},
+		{"b", false},
+		{"c", false},
+		{"d", true},
+		{"x", false},
+		{"y", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, gotOk := lc.Get(tt.key); gotOk != tt.wantOk {
+				t.Errorf("Get() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+
+	sort.Strings(removed)
+	if want := []string{"a", "b", "c", "x", "y"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("OnRemove keys = %v, want %v", removed, want)
+	}
+	if len(lc.dependents) != 0 {
+		t.Errorf("dependents = %v, want empty", lc.dependents)
+	}
+}
+
+func TestLCache_DepsEvicted(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	n := 1
+	// b依赖a，b先写入位于lru表尾，被淘汰后以不带依赖的方式重新写入
+	lc.SetWithDeps("b", &n, "a")
+	lc.SetVal("a", 1)
+	if got := lc.Prune(1); got != 1 {
+		t.Fatalf("Prune() = %v, want %v", got, 1)
+	}
+	if _, ok := lc.Get("b"); ok {
+		t.Fatalf("Get() after Prune gotOk = %v, want %v", ok, false)
+	}
+	if len(lc.dependents) != 0 {
+		t.Errorf("dependents = %v, want empty", lc.dependents)
+	}
+	lc.SetVal("b", 2)
+	lc.Del("a")
+	if _, ok := lc.Get("b"); !ok {
+		t.Errorf("Get() after Del gotOk = %v, want %v", ok, true)
+	}
+
+	// 被依赖的key因为容量被淘汰时不级联
+	lc.SetWithDeps("c", &n, "b")
+	if got := lc.Prune(1); got != 1 {
+		t.Fatalf("Prune() = %v, want %v", got, 1)
+	}
+	if _, ok := lc.Get("c"); !ok {
+		t.Errorf("Get() dependent after Prune gotOk = %v, want %v", ok, true)
+	}
+}
+
+func TestLCache_DepsExpired(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	n := 1
+	lc.SetWithTTL("a", &n, time.Millisecond*100)
+	lc.SetWithDeps("b", &n, "a")
+	time.Sleep(time.Millisecond * 300)
+
+	if _, ok := lc.Get("b"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+// injectUnlinked 测试用，将key对应的节点从lru链表中摘除但保留在map中
+func (lc *LCache[K, V]) injectUnlinked(key K) {
+	lc.runJob(func() {
+		lc.lock.Lock()
+		defer lc.lock.Unlock()
+
+		n := lc.kvStore[key]
+		n.prev.next = n.next
+		n.next.prev = n.prev
+		n.prev = nil
+		n.next = nil
+		lc.lruLen--
+	})
+}
+
+// injectOrphan 测试用，向lru链表中插入一个不在map中的节点
+func (lc *LCache[K, V]) injectOrphan(key K, value *V) {
+	lc.runJob(func() {
+		lc.lock.Lock()
+		defer lc.lock.Unlock()
+
+		n := &lruNode[K, V]{k: key, v: value}
+		n.exp.Store(lc.o.exp)
+		n.expAt.Store(time.Now().Add(lc.o.exp))
+		n.prev = lc.lruHead
+		n.next = lc.lruHead.next
+		lc.lruHead.next.prev = n
+		lc.lruHead.next = n
+		lc.lruLen++
+	})
+}
+
+// checkInvariants 测试用，检查map中的节点都在lru链表中，且链表中的节点都在map中
+// 链表只能在asyncJob中访问，检查通过runJob完成
+func (lc *LCache[K, V]) checkInvariants() (err error) {
+	lc.runJob(func() {
+		lc.lock.RLock()
+		defer lc.lock.RUnlock()
+
+		linked := 0
+		for n := lc.lruHead.next; n != lc.lruTail; n = n.next {
+			if lc.kvStore[n.k] != n {
+				err = fmt.Errorf("list node %v not in map", n.k)
+				return
+			}
+			linked++
+		}
+		if linked != len(lc.kvStore) || linked != lc.lruLen {
+			err = fmt.Errorf("linked = %d, map = %d, lruLen = %d", linked, len(lc.kvStore), lc.lruLen)
+		}
+	})
+	return err
+}
+
+func TestLCache_SelfHeal(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithSelfHeal(true))
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	time.Sleep(time.Millisecond * 10)
+
+	lc.injectUnlinked("a")
+	lc.injectOrphan("c", &n)
+	if err := lc.checkInvariants(); err == nil {
+		t.Fatalf("checkInvariants() err = %v, want non-nil", err)
+	}
+
+	time.Sleep(time.Millisecond * 120)
+	if err := lc.checkInvariants(); err != nil {
+		t.Errorf("checkInvariants() err = %v, want nil", err)
+	}
+	if got := lc.Stats().Repairs; got != 2 {
+		t.Errorf("Stats() Repairs = %v, want %v", got, 2)
+	}
+}
+
+func TestLCache_DelReleasesValue(t *testing.T) {
+	type big struct {
+		buf [1 << 20]byte
+	}
+	lc := NewCache[int, big](OptWithExpire(time.Second))
+
+	const keys = 32
+	var finalized atomic.Int32
+	pinned := make([]*lruNode[int, big], 0, keys)
+	for i := 0; i < keys; i++ {
+		v := &big{}
+		runtime.SetFinalizer(v, func(*big) { finalized.Add(1) })
+		lc.Set(i, v)
+	}
+	lc.lock.RLock()
+	for i := 0; i < keys; i++ {
+		// 模拟积压在channel中的消息继续持有已删除的节点
+		pinned = append(pinned, lc.kvStore[i])
+	}
+	lc.lock.RUnlock()
+
+	for i := 0; i < keys; i++ {
+		lc.Del(i)
+	}
+	for i := 0; i < 100 && finalized.Load() < keys; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond * 10)
+	}
+	if got := finalized.Load(); got != keys {
+		t.Errorf("finalized values = %v, want %v", got, keys)
+	}
+	runtime.KeepAlive(pinned)
+
+	if depth := lc.Stats().ChannelDepth; depth < 0 || depth > chanSize {
+		t.Errorf("Stats() ChannelDepth = %v, want in [0, %v]", depth, chanSize)
+	}
+}
+
+func TestLCache_Pairs(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	want := map[string]int{"a": 1, "b": 2, "c": 3}
+	for k, v := range want {
+		n := v
+		lc.Set(k, &n)
+	}
+	n := 4
+	lc.Set("d", &n)
+	lc.Del("d")
+
+	keys, values := lc.Pairs()
+	if len(keys) != len(values) {
+		t.Fatalf("Pairs() len(keys) = %v, len(values) = %v", len(keys), len(values))
+	}
+	got := make(map[string]int, len(keys))
+	for i, k := range keys {
+		got[k] = *values[i]
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pairs() = %v, want %v", got, want)
+	}
+}
+
+func TestLCache_Snapshot(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"single", nil},
+		{"shards", []Option{OptWithShards(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[string, int](append(tt.opts, OptWithExpire(time.Second), OptWithCleanupInterval(time.Hour))...)
+			defer lc.Close()
+
+			want := map[string]int{"a": 1, "b": 2, "c": 3}
+			for k, v := range want {
+				n := v
+				lc.Set(k, &n)
+			}
+			n := 4
+			lc.SetWithTTL("expired", &n, time.Millisecond)
+			lc.SetNegative("negative", time.Second)
+			time.Sleep(time.Millisecond * 50)
+
+			snapshot := lc.Snapshot()
+
+			// 之后的修改不影响已经取得的快照
+			lc.Set("d", &n)
+			lc.Del("a")
+			lc.Set("b", &n)
+
+			got := make(map[string]int, len(snapshot))
+			for k, v := range snapshot {
+				got[k] = *v
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Snapshot() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLCache_EvictBatch(t *testing.T) {
+	var evicted atomic.Int32
+	lc := NewCache[int, int](
+		OptWithExpire(time.Second),
+		OptWithMaxKeys(10),
+		OptWithEvictBatch(4),
+		OptWithOnRemove(func(key int, value *int) {
+			evicted.Add(1)
+		}),
+	)
+
+	for i := 0; i < 20; i++ {
+		n := i
+		lc.Set(i, &n)
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	// 第11、15、19个key写入时各淘汰4个，最终剩余8个
+	if got := evicted.Load(); got != 12 {
+		t.Errorf("evicted = %v, want %v", got, 12)
+	}
+	if keys, _ := lc.Pairs(); len(keys) != 8 {
+		t.Errorf("len(Pairs()) = %v, want %v", len(keys), 8)
+	}
+}
+
+func TestLCache_LastError(t *testing.T) {
+	lc := NewCache[string, int](
+		OptWithExpire(time.Millisecond*50),
+		OptWithOnRemove(func(key string, value *int) {
+			panic("release " + key)
+		}),
+	)
+
+	if err := lc.LastError(); err != nil {
+		t.Errorf("LastError() = %v, want nil", err)
+	}
+
+	n := 1
+	lc.Set("a", &n)
+	time.Sleep(time.Millisecond * 200)
+
+	err := lc.LastError()
+	if err == nil || !strings.Contains(err.Error(), "release a") {
+		t.Errorf("LastError() = %v, want callback panic", err)
+	}
+	if err := lc.LastError(); err != nil {
+		t.Errorf("LastError() after read = %v, want nil", err)
+	}
+	if got := lc.Stats().AsyncErrors; got != 1 {
+		t.Errorf("Stats() AsyncErrors = %v, want %v", got, 1)
+	}
+
+	// asyncJob在回调panic后仍然正常工作
+	lc.Set("b", &n)
+	if _, ok := lc.Get("b"); !ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, true)
+	}
+}
+
+func TestLCache_PauseExpiry(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 100))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+	time.Sleep(time.Millisecond * 10)
+
+	lc.PauseExpiry()
+	time.Sleep(time.Millisecond * 250)
+	if _, state := lc.GetState("a"); state != StateFresh {
+		t.Errorf("GetState() while paused state = %v, want %v", state, StateFresh)
+	}
+	// 暂停期间所有读写方法都把过了过期时刻的key视为未过期
+	if !lc.Contains("a") {
+		t.Errorf("Contains() while paused = %v, want %v", false, true)
+	}
+	if got := lc.Len(); got != 1 {
+		t.Errorf("Len() while paused = %v, want %v", got, 1)
+	}
+	m := 2
+	if lc.Add("a", &m) {
+		t.Errorf("Add() while paused = %v, want %v", true, false)
+	}
+	if v, ok := lc.Peek("a"); !ok || *v != 1 {
+		t.Errorf("Peek() while paused = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+	// 暂停期间写入的key从写入时开始计时
+	lc.Set("b", &n)
+	time.Sleep(time.Millisecond * 20)
+
+	// 恢复后过期时刻顺延了暂停的时长，不会立即过期
+	lc.ResumeExpiry()
+	if ttl, ok := lc.TTL("b"); !ok || ttl > time.Millisecond*100 {
+		t.Errorf("TTL() written while paused = %v, %v, want at most %v", ttl, ok, time.Millisecond*100)
+	}
+	time.Sleep(time.Millisecond * 30)
+	if _, state := lc.GetState("a"); state == StateMissing {
+		t.Errorf("GetState() after resume state = %v, want present", state)
+	}
+
+	time.Sleep(time.Millisecond * 200)
+	for _, k := range []string{"a", "b"} {
+		if _, state := lc.GetState(k); state != StateMissing {
+			t.Errorf("GetState(%q) after expiry state = %v, want %v", k, state, StateMissing)
+		}
+	}
+}
+
+func TestLCache_KeyStringer(t *testing.T) {
+	type userKey struct {
+		tenant string
+		id     int
+	}
+	lc := NewCache[userKey, int](
+		OptWithExpire(time.Second),
+		OptWithKeyStringer(func(key userKey) string {
+			return fmt.Sprintf("%s/%d", key.tenant, key.id)
+		}),
+	)
+
+	n := 1
+	lc.Set(userKey{"t1", 1}, &n)
+	lc.Set(userKey{"t2", 2}, &n)
+
+	if got, want := lc.DebugString(), "len=2 lru=[t2/2 t1/1]"; got != want {
+		t.Errorf("DebugString() = %q, want %q", got, want)
+	}
+
+	// 默认使用%v格式化
+	dc := NewCache[userKey, int](OptWithExpire(time.Second))
+	dc.Set(userKey{"t1", 1}, &n)
+	if got, want := dc.DebugString(), "len=1 lru=[{t1 1}]"; got != want {
+		t.Errorf("DebugString() = %q, want %q", got, want)
+	}
+}
+
+func TestLCache_GetOrSetWithTTL(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	var winners atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := i
+			ttl := time.Millisecond * 100
+			if i%2 == 1 {
+				ttl = time.Hour
+			}
+			actual, loaded := lc.GetOrSetWithTTL("lock", &n, ttl)
+			if !loaded {
+				winners.Add(1)
+			} else if actual == &n {
+				t.Errorf("GetOrSetWithTTL() loaded but returned own value")
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := winners.Load(); got != 1 {
+		t.Errorf("winners = %v, want %v", got, 1)
+	}
+
+	// 只有第一个写入者的ttl生效
+	v, _ := lc.GetState("lock")
+	time.Sleep(time.Millisecond * 250)
+	_, state := lc.GetState("lock")
+	if wantPresent := *v%2 == 1; (state != StateMissing) != wantPresent {
+		t.Errorf("GetState() state = %v, winner = %v", state, *v)
+	}
+}
+
+func TestLCache_GetOrSetWithTTLChecks(t *testing.T) {
+	errOdd := errors.New("odd value")
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithValidator(func(value *int) error {
+		if *value%2 == 1 {
+			return errOdd
+		}
+		return nil
+	}))
+	defer lc.Close()
+
+	// 未通过校验的value以及nil都不会写入
+	odd := 1
+	for _, v := range []*int{nil, &odd} {
+		if actual, loaded := lc.GetOrSetWithTTL("a", v, time.Minute); loaded || actual != v {
+			t.Errorf("GetOrSetWithTTL() = %v, %v, want %v, %v", actual, loaded, v, false)
+		}
+		if got, ok := lc.Get("a"); ok {
+			t.Errorf("Get() = %v, %v, want %v, %v", got, ok, nil, false)
+		}
+	}
+
+	// 命中统计与GetOrSet相同
+	lc.ResetStats()
+	even := 2
+	lc.GetOrSetWithTTL("b", &even, time.Minute)
+	lc.GetOrSetWithTTL("b", &even, time.Minute)
+	if stats := lc.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() Hits, Misses = %v, %v, want %v, %v", stats.Hits, stats.Misses, 1, 1)
+	}
+
+	// Seal之后不写入，已有的key照常返回
+	lc.Seal()
+	four := 4
+	if actual, loaded := lc.GetOrSetWithTTL("c", &four, time.Minute); loaded || actual != &four {
+		t.Errorf("GetOrSetWithTTL() = %v, %v, want %v, %v", actual, loaded, &four, false)
+	}
+	if lc.Contains("c") {
+		t.Errorf("Contains(c) = %v, want %v", true, false)
+	}
+	if actual, loaded := lc.GetOrSetWithTTL("b", &four, time.Minute); !loaded || *actual != 2 {
+		t.Errorf("GetOrSetWithTTL() = %v, %v, want %v, %v", actual, loaded, 2, true)
+	}
+}
+
+func TestLCache_StaleKeys(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond*100), OptWithGracePeriod(time.Second))
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	lc.SetWithTTL("c", &n, time.Hour)
+	time.Sleep(time.Millisecond * 200)
+
+	got := lc.StaleKeys()
+	sort.Strings(got)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("StaleKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestLCache_MaxTTL(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Hour), OptWithMaxTTL(time.Second))
+
+	n := 1
+	start := time.Now()
+	lc.SetWithTTL("a", &n, time.Hour*24*365*100)
+	lc.Set("b", &n)
+	lc.SetWithTTL("c", &n, time.Millisecond*100)
+	time.Sleep(time.Millisecond * 10)
+
+	tests := []struct {
+		key string
+		ttl time.Duration
+	}{
+		{"a", time.Second},
+		{"b", time.Second},
+		{"c", time.Millisecond * 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			lc.lock.RLock()
+			expAt := lc.kvStore[tt.key].expAt.Load()
+			lc.lock.RUnlock()
+			if expAt.Before(start.Add(tt.ttl)) || expAt.After(time.Now().Add(tt.ttl)) {
+				t.Errorf("expAt = %v, want about %v", expAt.Sub(start), tt.ttl)
+			}
+		})
+	}
+}
+
+func TestLCache_MissStreak(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithMissTracking(2))
+
+	for i := 1; i <= 3; i++ {
+		lc.Get("a")
+		if got := lc.MissStreak("a"); got != i {
+			t.Errorf("MissStreak() = %v, want %v", got, i)
+		}
+	}
+
+	n := 1
+	lc.Set("a", &n)
+	if got := lc.MissStreak("a"); got != 0 {
+		t.Errorf("MissStreak() after Set = %v, want %v", got, 0)
+	}
+
+	// 记录的key数量不超过上限
+	for _, k := range []string{"b", "c", "d"} {
+		lc.Get(k)
+	}
+	if got := len(lc.missCounts); got > 2 {
+		t.Errorf("len(missCounts) = %v, want <= %v", got, 2)
+	}
+
+	// 未开启时总是返回0
+	dc := NewCache[string, int](OptWithExpire(time.Second))
+	dc.Get("a")
+	if got := dc.MissStreak("a"); got != 0 {
+		t.Errorf("MissStreak() = %v, want %v", got, 0)
+	}
+}
+
+func TestLCache_KeyTransform(t *testing.T) {
+	// 两个租户的缓存共享同一份存储逻辑，key互不冲突
+	tenants := map[string]*LCache[string, int]{
+		"t1": NewCache[string, int](OptWithExpire(time.Second), OptWithKeyPrefix("t1:")),
+		"t2": NewCache[string, int](OptWithExpire(time.Second), OptWithKeyTransform(func(key string) string {
+			return "t2:" + key
+		})),
+	}
+	for tenant, lc := range tenants {
+		n := len(tenant)
+		lc.Set("a", &n)
+		lc.SetWithDeps("b", &n, "a")
+	}
+
+	for tenant, lc := range tenants {
+		t.Run(tenant, func(t *testing.T) {
+			if _, ok := lc.Get("a"); !ok {
+				t.Errorf("Get() gotOk = %v, want %v", ok, true)
+			}
+			keys, _ := lc.Pairs()
+			sort.Strings(keys)
+			if want := []string{tenant + ":a", tenant + ":b"}; !reflect.DeepEqual(keys, want) {
+				t.Errorf("Pairs() keys = %v, want %v", keys, want)
+			}
+
+			// 依赖的key同样经过转换
+			lc.Del("a")
+			if _, ok := lc.Get("b"); ok {
+				t.Errorf("Get() gotOk = %v, want %v", ok, false)
+			}
+		})
+	}
+}
+
+func TestLCache_EntriesModifiedSince(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	time.Sleep(time.Millisecond * 20)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond * 20)
+
+	// 覆盖写会更新写入时刻，Get不会
+	m := 2
+	lc.Set("b", &m)
+	lc.Set("c", &m)
+	lc.Get("a")
+
+	var got []string
+	for _, e := range lc.EntriesModifiedSince(cutoff) {
+		if *e.Value != 2 || !e.LastSet.After(cutoff) {
+			t.Errorf("entry %v = %v, LastSet %v, want value 2 set after cutoff", e.Key, *e.Value, e.LastSet)
+		}
+		got = append(got, e.Key)
+	}
+	sort.Strings(got)
+	if want := []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EntriesModifiedSince() keys = %v, want %v", got, want)
+	}
+}
+
+func TestLCache_ShardSizes(t *testing.T) {
+	// 小于90的key全部落在0号分片，其余按key取模
+	skewed := OptWithKeyHasher(func(key int) uint64 {
+		if key < 90 {
+			return 0
+		}
+		return uint64(key)
+	})
+	tests := []struct {
+		name string
+		opts []Option
+		want []int
+	}{
+		{"unsharded", nil, []int{100}},
+		{"skewed shards", []Option{OptWithShards(4), skewed}, []int{92, 2, 3, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[int, int](append(tt.opts, OptWithExpire(time.Second))...)
+			defer lc.Close()
+			for i := 0; i < 100; i++ {
+				lc.SetVal(i, i)
+			}
+
+			if got := lc.ShardSizes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShardSizes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLCache_CloseConcurrent(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+
+	wg := sync.WaitGroup{}
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				k := i<<32 | j
+				lc.Set(k, &j)
+				lc.Get(k)
+			}
+		}(i)
+	}
+
+	time.Sleep(time.Millisecond * 10)
+	closed := make(chan struct{})
+	go func() {
+		lc.Close()
+		lc.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Close() deadlock")
+	}
+	select {
+	case <-lc.exited:
+	default:
+		t.Errorf("asyncJob still running after Close()")
+	}
+
+	// Close之后的读写不会panic或阻塞
+	time.Sleep(time.Millisecond * 10)
+	close(stop)
+	wg.Wait()
+	v := 1
+	lc.Set(-1, &v)
+	if _, ok := lc.Get(-1); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+	lc.Del(-1)
+	lc.PauseExpiry()
+}
+
+func TestLCache_Transform(t *testing.T) {
+	var removedKeys []string
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithOnRemove(func(key string, value *int) {
+		removedKeys = append(removedKeys, key)
+	}))
+	for k, v := range map[string]int{"a": 8, "b": 3, "c": 1, "d": 0} {
+		v := v
+		lc.Set(k, &v)
+	}
+
+	// 所有值减半，减到0的删除
+	lc.Transform(func(key string, value *int) (*int, bool) {
+		v := *value / 2
+		return &v, v > 0
+	})
+
+	want := map[string]int{"a": 4, "b": 1}
+	keys, values := lc.Pairs()
+	if len(keys) != len(want) {
+		t.Errorf("Pairs() keys = %v, want %v", keys, want)
+	}
+	for i, k := range keys {
+		if *values[i] != want[k] {
+			t.Errorf("Get(%q) = %v, want %v", k, *values[i], want[k])
+		}
+	}
+	sort.Strings(removedKeys)
+	if !reflect.DeepEqual(removedKeys, []string{"c", "d"}) {
+		t.Errorf("removed keys = %v, want %v", removedKeys, []string{"c", "d"})
+	}
+	for _, k := range []string{"c", "d"} {
+		if _, ok := lc.Get(k); ok {
+			t.Errorf("Get(%q) gotOk = %v, want %v", k, ok, false)
+		}
+	}
+}
+
+func TestLCache_TransformPanic(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+	lc.SetVal("a", 1)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Transform() panic = %v, want %v", r, "boom")
+			}
+		}()
+		lc.Transform(func(key string, value *int) (*int, bool) {
+			panic("boom")
+		})
+	}()
+
+	// fn panic之后写锁已经释放，读写不会阻塞
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lc.SetVal("b", 2)
+		lc.Peek("a")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache blocked after Transform panic")
+	}
+}
+
+func TestLCache_TransformReplace(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		replaced []int
+	)
+	lc := NewCache[string, int](
+		OptWithExpire(time.Second),
+		OptWithValidator(func(value *int) error {
+			if *value < 0 {
+				return errors.New("negative")
+			}
+			return nil
+		}),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			if reason == ReasonReplaced {
+				mu.Lock()
+				replaced = append(replaced, *value)
+				mu.Unlock()
+			}
+		}),
+	)
+	defer lc.Close()
+	events := lc.Events()
+	lc.SetVal("a", 1)
+	lc.SetVal("b", 2)
+	lc.SetVal("c", 3)
+
+	// a被替换，b返回nil、c返回未通过校验的value，都保留原来的value
+	lc.Transform(func(key string, value *int) (*int, bool) {
+		switch key {
+		case "a":
+			v := 10
+			return &v, true
+		case "b":
+			return nil, true
+		}
+		v := -1
+		return &v, true
+	})
+
+	for k, want := range map[string]int{"a": 10, "b": 2, "c": 3} {
+		if v, ok := lc.GetVal(k); !ok || v != want {
+			t.Errorf("GetVal(%q) = %v, %v, want %v, %v", k, v, ok, want, true)
+		}
+	}
+	if !reflect.DeepEqual(replaced, []int{1}) {
+		t.Errorf("OnEvict() replaced = %v, want %v", replaced, []int{1})
+	}
+	// 替换同样发送事件
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Key == "a" && e.Type == ReasonReplaced {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Events() no ReasonReplaced event for %q", "a")
+		}
+	}
+}
+
+func TestLCache_GetEntry(t *testing.T) {
+	exp := time.Millisecond * 200
+	lc := NewCache[string, int](OptWithExpire(exp))
+
+	if _, ok := lc.GetEntry("a"); ok {
+		t.Errorf("GetEntry() gotOk = %v, want %v", ok, false)
+	}
+
+	v := 1
+	before := time.Now()
+	lc.Set("a", &v)
+	time.Sleep(time.Millisecond * 20)
+	v2 := 2
+	lc.Set("a", &v2)
+	time.Sleep(time.Millisecond * 20)
+
+	now := time.Now()
+	e, ok := lc.GetEntry("a")
+	after := time.Now()
+	if !ok {
+		t.Fatalf("GetEntry() gotOk = %v, want %v", ok, true)
+	}
+	if e.Key != "a" || e.Value != &v2 {
+		t.Errorf("GetEntry() = %v, %v, want a, %v", e.Key, e.Value, &v2)
+	}
+	if e.TTL != exp {
+		t.Errorf("GetEntry().TTL = %v, want %v", e.TTL, exp)
+	}
+	if e.CreatedAt.Before(before) || !e.CreatedAt.Before(e.LastSet) || e.LastSet.After(now) {
+		t.Errorf("GetEntry() CreatedAt = %v, LastSet = %v, want CreatedAt < LastSet <= %v", e.CreatedAt, e.LastSet, now)
+	}
+	if e.ExpAt.Before(now.Add(exp)) || e.ExpAt.After(after.Add(exp)) {
+		t.Errorf("GetEntry().ExpAt = %v, want around %v", e.ExpAt, now.Add(exp))
+	}
+	if e.Remaining <= 0 || e.Remaining > exp {
+		t.Errorf("GetEntry().Remaining = %v, want (0, %v]", e.Remaining, exp)
+	}
+}
+
+func TestLCache_Len(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 100))
+	for i, k := range []string{"a", "b", "c"} {
+		i := i
+		lc.Set(k, &i)
+	}
+	lc.DebugString()
+	if got := lc.Len(); got != 3 {
+		t.Errorf("Len() = %v, want 3", got)
+	}
+
+	lc.Del("a")
+	if got := lc.Len(); got != 2 {
+		t.Errorf("Len() = %v, want 2", got)
+	}
+
+	// 过期之后即使还没被清理也不计入，清理之后从map中删除
+	time.Sleep(time.Millisecond * 120)
+	if got := lc.Len(); got != 0 {
+		t.Errorf("Len() = %v, want 0", got)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if got := lc.ShardSizes()[0]; got != 0 {
+		t.Errorf("ShardSizes() = %v, want 0 after sweep", got)
+	}
+}
+
+func TestLCache_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	caches := make([]*LCache[string, int], 10)
+	for i := range caches {
+		caches[i] = NewCache[string, int](OptWithExpire(time.Second))
+		caches[i].Set("a", &i)
+	}
+	if got := runtime.NumGoroutine(); got < before+len(caches) {
+		t.Fatalf("NumGoroutine() = %v, want >= %v", got, before+len(caches))
+	}
+
+	for _, lc := range caches {
+		lc.Close()
+	}
+	// Close会等待asyncJob退出，但goroutine真正结束可能稍有延迟
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > before {
+		t.Errorf("NumGoroutine() = %v, want <= %v", got, before)
+	}
+
+	// Close之后的调用不会panic
+	lc := caches[0]
+	v := 1
+	lc.Set("b", &v)
+	lc.Get("a")
+	lc.Del("a")
+	lc.Close()
+}
+
+func TestLCache_Peek(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 200))
+
+	n := 1
+	lc.Set("peek", &n)
+	lc.Set("get", &n)
+	time.Sleep(time.Millisecond * 120)
+
+	if v, ok := lc.Peek("peek"); !ok || *v != 1 {
+		t.Errorf("Peek() = %v, %v, want 1, true", v, ok)
+	}
+	lc.Get("get")
+	time.Sleep(time.Millisecond * 120)
+
+	// Peek没有刷新过期时间，Get刷新了
+	if _, ok := lc.Peek("peek"); ok {
+		t.Errorf("Peek() gotOk = %v, want %v", ok, false)
+	}
+	if _, ok := lc.Peek("get"); !ok {
+		t.Errorf("Peek() gotOk = %v, want %v", ok, true)
+	}
+	if _, ok := lc.Peek("none"); ok {
+		t.Errorf("Peek() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_StatsCounters(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond*100), OptWithMaxKeys(2))
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	lc.Get("a")
+	lc.Get("a")
+	lc.Get("none")
+	lc.Set("c", &n) // 淘汰b
+	lc.DebugString()
+	lc.Get("b")
+	time.Sleep(time.Millisecond * 250) // a、c过期
+
+	got := lc.Stats()
+	want := CacheStats{Hits: 2, Misses: 2, Sets: 3, Evictions: 1, Expirations: 2}
+	if got.Hits != want.Hits || got.Misses != want.Misses || got.Sets != want.Sets ||
+		got.Evictions != want.Evictions || got.Expirations != want.Expirations {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLCache_OptionGetters(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantTTL       time.Duration
+		wantMaxKeys   int
+		wantMaxMemory int
+	}{
+		{"default", []Option{OptWithExpire(time.Second)}, time.Second, 0, 0},
+		{"limits", []Option{OptWithExpire(time.Minute), OptWithMaxKeys(100), OptWithMaxMemory("2KB")}, time.Minute, 100, 2048},
+		{"max_ttl", []Option{OptWithExpire(time.Hour), OptWithMaxTTL(time.Minute)}, time.Minute, 0, 0},
+		{"shards", []Option{OptWithExpire(time.Second), OptWithMaxKeys(100), OptWithShards(8)}, time.Second, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[string, int](tt.opts...)
+			defer lc.Close()
+
+			if got := lc.DefaultTTL(); got != tt.wantTTL {
+				t.Errorf("DefaultTTL() = %v, want %v", got, tt.wantTTL)
+			}
+			if got := lc.MaxKeys(); got != tt.wantMaxKeys {
+				t.Errorf("MaxKeys() = %v, want %v", got, tt.wantMaxKeys)
+			}
+			if got := lc.MaxMemory(); got != tt.wantMaxMemory {
+				t.Errorf("MaxMemory() = %v, want %v", got, tt.wantMaxMemory)
+			}
+		})
+	}
+}
+
+func TestLCache_ResetStats(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithMaxKeys(2))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	lc.Get("a")
+	lc.Get("none")
+	lc.Set("c", &n) // 淘汰b
+	lc.DebugString()
+
+	lc.ResetStats()
+	if got := lc.Stats(); got.Hits != 0 || got.Misses != 0 || got.Sets != 0 || got.Evictions != 0 || got.Expirations != 0 {
+		t.Errorf("Stats() = %+v, want zero counters", got)
+	}
+	if got := lc.Len(); got != 2 {
+		t.Errorf("Len() = %v, want %v", got, 2)
+	}
+
+	lc.Get("a")
+	lc.Set("d", &n) // 淘汰c
+	lc.DebugString()
+
+	got := lc.Stats()
+	want := CacheStats{Hits: 1, Sets: 1, Evictions: 1}
+	if got.Hits != want.Hits || got.Misses != want.Misses || got.Sets != want.Sets ||
+		got.Evictions != want.Evictions || got.Expirations != want.Expirations {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLCache_CompactConcurrentWrites(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+	defer lc.Close()
+
+	// 多个P让写入与清理真正并行
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	n := 1
+	const writers, perWriter = 4, 2000
+	stop := make(chan struct{})
+	compacted := make(chan struct{})
+	go func() {
+		defer close(compacted)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			lc.runJob(lc.compact)
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				k := w*perWriter + i
+				lc.Set(k, &k)
+				// 同时写入并删除临时的key，让清理反复发生
+				for j := 0; j < 2; j++ {
+					lc.Set(-k-1, &n)
+					lc.Del(-k - 1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+	<-compacted
+
+	for k := 0; k < writers*perWriter; k++ {
+		if v, ok := lc.Peek(k); !ok || *v != k {
+			t.Fatalf("Peek(%d) = %v, %v, want %v, %v", k, v, ok, k, true)
+		}
+	}
+}
+
+func TestLCache_Clear(t *testing.T) {
+	var flushed atomic.Int32
+	lc := NewCache[int, int](OptWithExpire(time.Second), OptWithOnEvict(func(key int, value *int, reason EvictReason) {
+		if reason == ReasonFlushed {
+			flushed.Add(1)
+		}
+	}))
+	for i := 0; i < 100; i++ {
+		i := i
+		lc.Set(i, &i)
+	}
+	lc.Get(1)
+
+	// Clear期间并发读写不受影响
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 1000; ; j++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			lc.Set(j, &j)
+			lc.Get(j)
+		}
+	}()
+	lc.Clear()
+	close(stop)
+	wg.Wait()
+
+	if got := flushed.Load(); got < 100 {
+		t.Errorf("OnEvict(ReasonFlushed) count = %v, want >= 100", got)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := lc.Get(i); ok {
+			t.Errorf("Get(%v) gotOk = %v, want %v", i, ok, false)
+		}
+	}
+
+	lc.Clear()
+	if got := lc.Len(); got != 0 {
+		t.Errorf("Len() = %v, want 0", got)
+	}
+	n := 1
+	lc.Set(1, &n)
+	lc.DebugString()
+	if err := lc.checkInvariants(); err != nil {
+		t.Errorf("checkInvariants() err = %v", err)
+	}
+}
+
+func TestLCache_Keys(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+
+	n := 1
+	for _, k := range []string{"a", "b", "c", "d"} {
+		lc.Set(k, &n)
+	}
+	lc.SetWithTTL("short", &n, time.Millisecond*10)
+	lc.Del("b")
+	time.Sleep(time.Millisecond * 20)
+
+	// short已经过期，即使还没有被清理也不返回
+	got := lc.Keys()
+	sort.Strings(got)
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+}
+
+func TestLCache_Range(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Second))
+	for i := 1; i <= 10; i++ {
+		i := i
+		lc.Set(i, &i)
+	}
+
+	sum := 0
+	lc.Range(func(key int, value *int) bool {
+		sum += *value
+		return true
+	})
+	if sum != 55 {
+		t.Errorf("Range() sum = %v, want 55", sum)
+	}
+
+	// 返回false时停止遍历
+	visited := 0
+	lc.Range(func(key int, value *int) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("Range() visited = %v, want 3", visited)
+	}
+}
+
+// TestLCache_RaceStress 并发地Set/Get/Del少量热点key，配合-race检查asyncJob与读写方之间的数据竞争
+func TestLCache_RaceStress(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+	defer lc.Close()
+
+	// 预先写入足够多不会被删除的key，避免测试期间触发map清理
+	const (
+		cold    = 10000
+		hot     = 64
+		workers = 8
+		rounds  = 2000
+	)
+	for i := hot; i < hot+cold; i++ {
+		i := i
+		lc.Set(i, &i)
+	}
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				k := (w*rounds + i) % hot
+				switch i % 3 {
+				case 0:
+					n := i
+					lc.Set(k, &n)
+				case 1:
+					if v, ok := lc.Get(k); ok && v == nil {
+						t.Errorf("Get(%v) got nil value", k)
+					}
+					lc.Get(hot + i%cold)
+				case 2:
+					lc.Del(k)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if err := lc.checkInvariants(); err != nil {
+		t.Errorf("checkInvariants() err = %v, want nil", err)
+	}
+}
+
+func TestLCache_UpdateBuffer(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute), OptWithUpdateBuffer(2))
+	defer lc.Close()
+
+	// 让asyncJob阻塞在一个job中，模拟asyncJob处理缓慢
+	release := make(chan struct{})
+	blocked := make(chan struct{})
+	go lc.runJob(func() {
+		close(blocked)
+		<-release
+	})
+	<-blocked
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			i := i
+			lc.Set(i, &i)
+			lc.Get(i)
+		}
+		lc.Del(0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Set/Get blocked while asyncJob is busy")
+	}
+	close(release)
+
+	if got := lc.Stats().DroppedUpdates; got == 0 {
+		t.Errorf("Stats() DroppedUpdates = %v, want > 0", got)
+	}
+	// 暂存的写入和删除最终都会反映到lru链表中
+	if err := lc.checkInvariants(); err != nil {
+		t.Errorf("checkInvariants() err = %v, want nil", err)
+	}
+	if got := lc.Len(); got != 99 {
+		t.Errorf("Len() = %v, want %v", got, 99)
+	}
+}
+
+// BenchmarkLCache_GetSlowAsync asyncJob被拖慢时Get的吞吐，channel已满时Get放弃刷新而不是阻塞
+func BenchmarkLCache_GetSlowAsync(b *testing.B) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+	defer lc.Close()
+	for i := 0; i < 1024; i++ {
+		n := i
+		lc.Set(i, &n)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				lc.runJob(func() { time.Sleep(time.Millisecond) })
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			lc.Get(i & 1023)
+			i++
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-stopped
+}
+
+func TestLCache_GetOrSet(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	var winners atomic.Int32
+	var winner atomic.Pointer[int]
+	results := make([]*int, 50)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := i
+			actual, loaded := lc.GetOrSet("k", &n)
+			if !loaded {
+				if actual != &n {
+					t.Errorf("GetOrSet() not loaded but returned other value")
+				}
+				winners.Add(1)
+				winner.Store(actual)
+			}
+			results[i] = actual
+		}(i)
+	}
+	wg.Wait()
+	if got := winners.Load(); got != 1 {
+		t.Fatalf("winners = %v, want %v", got, 1)
+	}
+	for i, v := range results {
+		if v != winner.Load() {
+			t.Errorf("GetOrSet() #%d actual = %v, want %v", i, *v, *winner.Load())
+		}
+	}
+	if v, ok := lc.Get("k"); !ok || v != winner.Load() {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, winner.Load(), true)
+	}
+}
+
+func TestLCache_Replace(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	a, b := 1, 2
+	if lc.Replace("k", &a) {
+		t.Errorf("Replace() = %v, want %v", true, false)
+	}
+	if lc.Contains("k") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+	if got := lc.Len(); got != 0 {
+		t.Errorf("Len() = %v, want %v", got, 0)
+	}
+
+	lc.Set("k", &a)
+	if !lc.Replace("k", &b) {
+		t.Errorf("Replace() = %v, want %v", false, true)
+	}
+	if v, ok := lc.Get("k"); !ok || v != &b {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, &b, true)
+	}
+}
+
+func TestLCache_Add(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	a, b := 1, 2
+	if !lc.Add("k", &a) {
+		t.Errorf("Add() = %v, want %v", false, true)
+	}
+	if lc.Add("k", &b) {
+		t.Errorf("Add() = %v, want %v", true, false)
+	}
+	if v, ok := lc.Get("k"); !ok || v != &a {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, &a, true)
+	}
+}
+
+func TestLCache_CompareAndDelete(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	eq := func(a, b *int) bool { return *a == *b }
+	v1 := 1
+	lc.Set("k", &v1)
+	stale, _ := lc.Get("k")
+
+	// 另一个goroutine在删除之前覆盖了value，过时的删除被拒绝
+	refreshed := make(chan struct{})
+	v2 := 2
+	go func() {
+		lc.Set("k", &v2)
+		close(refreshed)
+	}()
+	<-refreshed
+	if lc.CompareAndDelete("k", stale, eq) {
+		t.Errorf("CompareAndDelete() = %v, want %v", true, false)
+	}
+	if v, ok := lc.Get("k"); !ok || v != &v2 {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, &v2, true)
+	}
+
+	// eq按值比较，不要求是同一个指针
+	expect := 2
+	if !lc.CompareAndDelete("k", &expect, eq) {
+		t.Errorf("CompareAndDelete() = %v, want %v", false, true)
+	}
+	if lc.Contains("k") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+	if lc.CompareAndDelete("k", &expect, eq) {
+		t.Errorf("CompareAndDelete() = %v, want %v", true, false)
+	}
+}
+
+func TestLCache_CompareAndDeleteConcurrent(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	// 每个goroutine只删除自己写入的value，删除成功时key一定还是自己的value
+	var deleted atomic.Int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				v := i*1000 + j
+				lc.Set(j%10, &v)
+				if lc.CompareAndDelete(j%10, &v, nil) {
+					deleted.Add(1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if deleted.Load() == 0 {
+		t.Errorf("CompareAndDelete() never succeeded")
+	}
+}
+
+func TestLCache_GetAndDelete(t *testing.T) {
+	var taken atomic.Int32
+	lc := NewCache[string, int](
+		OptWithExpire(time.Second),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			if reason == ReasonTaken {
+				taken.Add(1)
+			}
+		}),
+	)
+	defer lc.Close()
+
+	for round := 0; round < 100; round++ {
+		token := round
+		lc.Set("token", &token)
+
+		// 两个goroutine同时取同一个key，只有一个能取到
+		var got atomic.Int32
+		start := make(chan struct{})
+		wg := sync.WaitGroup{}
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				if v, ok := lc.GetAndDelete("token"); ok {
+					if *v != token {
+						t.Errorf("GetAndDelete() = %v, want %v", *v, token)
+					}
+					got.Add(1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if got.Load() != 1 {
+			t.Fatalf("GetAndDelete() succeeded %d times, want %d", got.Load(), 1)
+		}
+	}
+	if got := taken.Load(); got != 100 {
+		t.Errorf("OnEvict() taken = %v, want %v", got, 100)
+	}
+	if _, ok := lc.Get("token"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_GetWithVersion(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	v1, v2 := 1, 2
+	lc.Set("a", &v1)
+	_, ver1, ok := lc.GetWithVersion("a")
+	if !ok {
+		t.Fatalf("GetWithVersion() gotOk = %v, want %v", ok, true)
+	}
+
+	// 读取不改变版本号
+	if _, got, _ := lc.GetWithVersion("a"); got != ver1 {
+		t.Errorf("GetWithVersion() version = %v, want %v", got, ver1)
+	}
+
+	lc.Set("a", &v2)
+	value, ver2, ok := lc.GetWithVersion("a")
+	if !ok || value != &v2 || ver2 <= ver1 {
+		t.Errorf("GetWithVersion() = %v, %v, %v, want %v, > %v, %v", value, ver2, ok, &v2, ver1, true)
+	}
+
+	lc.Replace("a", &v1)
+	_, ver3, _ := lc.GetWithVersion("a")
+	if ver3 <= ver2 {
+		t.Errorf("GetWithVersion() version = %v, want > %v", ver3, ver2)
+	}
+
+	// 删除之后重新写入，版本号不会重复
+	lc.Del("a")
+	lc.Set("a", &v1)
+	if _, ver4, _ := lc.GetWithVersion("a"); ver4 <= ver3 {
+		t.Errorf("GetWithVersion() version = %v, want > %v", ver4, ver3)
+	}
+
+	if _, _, ok := lc.GetWithVersion("none"); ok {
+		t.Errorf("GetWithVersion() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_SetVal(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	// 循环变量直接按值写入，每个key保存各自的副本
+	var shared int
+	for i, k := range []string{"a", "b", "c"} {
+		shared = i
+		lc.SetVal(k, shared)
+	}
+	for i, k := range []string{"a", "b", "c"} {
+		if got, ok := lc.GetVal(k); !ok || got != i {
+			t.Errorf("GetVal(%v) = %v, %v, want %v, %v", k, got, ok, i, true)
+		}
+	}
+
+	if got, ok := lc.GetVal("x"); ok || got != 0 {
+		t.Errorf("GetVal() = %v, %v, want %v, %v", got, ok, 0, false)
+	}
+}
+
+func TestLCache_Contains(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 100))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+	time.Sleep(time.Millisecond * 10)
+	if !lc.Contains("a") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+	if lc.Contains("b") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+
+	// 持续调用Contains不会延长过期时间
+	for i := 0; i < 30; i++ {
+		lc.Contains("a")
+		time.Sleep(time.Millisecond * 10)
+	}
+	if lc.Contains("a") {
+		t.Errorf("Contains() = %v, want %v", true, false)
+	}
+	if _, state := lc.GetState("a"); state != StateMissing {
+		t.Errorf("GetState() state = %v, want %v", state, StateMissing)
+	}
+}
+
+func TestLCache_TTL(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	n := 1
+	lc.SetWithTTL("a", &n, time.Millisecond*500)
+	time.Sleep(time.Millisecond * 100)
+
+	got, ok := lc.TTL("a")
+	if !ok {
+		t.Fatalf("TTL() gotOk = %v, want %v", ok, true)
+	}
+	if want := time.Millisecond * 400; got > want || got < want-time.Millisecond*50 {
+		t.Errorf("TTL() = %v, want about %v", got, want)
+	}
+
+	if _, ok := lc.TTL("b"); ok {
+		t.Errorf("TTL() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_GetWithTTL(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithFixedExpire())
+	defer lc.Close()
+
+	n := 1
+	lc.SetWithTTL("a", &n, time.Millisecond*500)
+	time.Sleep(time.Millisecond * 50)
+
+	// 固定过期时间，剩余时间随调用逐渐减少
+	var last time.Duration
+	for i := 0; i < 3; i++ {
+		v, ttl, ok := lc.GetWithTTL("a")
+		if !ok || v != &n {
+			t.Fatalf("GetWithTTL() = %v, %v, want %v, %v", v, ok, &n, true)
+		}
+		if ttl <= 0 || ttl > time.Millisecond*500 {
+			t.Errorf("GetWithTTL() ttl = %v, want in (0, %v]", ttl, time.Millisecond*500)
+		}
+		if i > 0 && ttl >= last {
+			t.Errorf("GetWithTTL() ttl = %v, want less than %v", ttl, last)
+		}
+		last = ttl
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	if _, _, ok := lc.GetWithTTL("b"); ok {
+		t.Errorf("GetWithTTL() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestLCache_Touch(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 100))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond * 30)
+		if !lc.Touch("a") {
+			t.Fatalf("Touch() = %v, want %v", false, true)
+		}
+	}
+
+	// 300ms之后a仍然存在，没有被Touch的b已经过期
+	if !lc.Contains("a") {
+		t.Errorf("Contains(a) = %v, want %v", false, true)
+	}
+	if lc.Touch("b") {
+		t.Errorf("Touch(b) = %v, want %v", true, false)
+	}
+	if lc.Touch("c") {
+		t.Errorf("Touch(c) = %v, want %v", true, false)
+	}
+}
+
+func TestLCache_HitRate(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithCleanupInterval(time.Millisecond*20))
+	defer lc.Close()
+
+	if got := lc.HitRate(time.Second); got != 0 {
+		t.Errorf("HitRate() = %v, want %v", got, 0)
+	}
+
+	n := 1
+	lc.Set("a", &n)
+	for i := 0; i < 3; i++ {
+		lc.Get("a")
+	}
+	lc.Get("b")
+	if got := lc.HitRate(time.Second); got != 0.75 {
+		t.Errorf("HitRate() = %v, want %v", got, 0.75)
+	}
+
+	// 超出窗口之后之前的读取不再计入
+	time.Sleep(time.Millisecond * 200)
+	if got := lc.HitRate(time.Millisecond * 100); got != 0 {
+		t.Errorf("HitRate(100ms) = %v, want %v", got, 0)
+	}
+	lc.Get("a")
+	lc.Get("b")
+	if got := lc.HitRate(time.Millisecond * 100); got != 0.5 {
+		t.Errorf("HitRate(100ms) = %v, want %v", got, 0.5)
+	}
+	if got, want := lc.HitRate(time.Second), 4.0/6; got != want {
+		t.Errorf("HitRate(1s) = %v, want %v", got, want)
+	}
+}
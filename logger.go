@@ -0,0 +1,20 @@
+package localcache
+
+// Logger 输出诊断信息，*log.Logger满足该接口
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// OptWithLogger 设置输出诊断信息的Logger，例如key过期、asyncJob遇到的错误；默认不输出
+func OptWithLogger(l Logger) Option {
+	return func(co *CacheOptions) {
+		co.logger = l
+	}
+}
+
+// logf 通过Logger输出诊断信息，未设置Logger时不做任何事
+func (lc *LCache[K, V]) logf(format string, args ...any) {
+	if lc.o.logger != nil {
+		lc.o.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,47 @@
+package localcache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type captureLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) Printf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *captureLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestLCache_Logger(t *testing.T) {
+	logger := &captureLogger{}
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond*50), OptWithLogger(logger))
+
+	n := 1
+	lc.Set("a", &n)
+	time.Sleep(time.Millisecond * 150)
+
+	if got := logger.String(); !strings.Contains(got, "key a expired") {
+		t.Errorf("logger = %q, want expiration of a", got)
+	}
+
+	lc.runJob(lc.dumpLink)
+	if got := logger.String(); !strings.Contains(got, "dumpLink:") {
+		t.Errorf("logger = %q, want dumpLink output", got)
+	}
+
+	// 默认不输出
+	NewCache[string, int](OptWithExpire(time.Millisecond*10)).Set("a", &n)
+}
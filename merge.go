@@ -0,0 +1,176 @@
+package localcache
+
+import "time"
+
+// absorbEntry Absorb从src中读取的一个key
+type absorbEntry[K comparable, V any] struct {
+	k       K
+	v       *V
+	exp     time.Duration
+	expAt   time.Time
+	persist bool
+	prio    int
+}
+
+// Absorb 将src中所有未过期的key合并到dst中
+// 两边都存在的key由onConflict决定最终的value，onConflict为nil时保留dst的value；过期时刻取两者中较晚的一个，永不过期视为最晚
+// dst中不存在的key保留它在src中的过期时刻；未通过dst校验的value会被跳过，dst已经Seal时返回ErrSealed
+// src的内容在一次读锁内读取(分片的src逐个分片读取)，合并期间对src的修改不会影响结果
+func (dst *LCache[K, V]) Absorb(src *LCache[K, V], onConflict func(dstVal, srcVal *V) *V) error {
+	now := time.Now()
+	return dst.absorb(src.absorbEntries(now), now, onConflict)
+}
+
+// absorbEntries 读取src中所有未过期的key
+func (src *LCache[K, V]) absorbEntries(now time.Time) []absorbEntry[K, V] {
+	if src.shards != nil {
+		var entries []absorbEntry[K, V]
+		for _, shard := range src.shards {
+			entries = append(entries, shard.absorbEntries(now)...)
+		}
+		return entries
+	}
+
+	src.lock.RLock()
+	defer src.lock.RUnlock()
+
+	entries := make([]absorbEntry[K, V], 0, len(src.kvStore))
+	// Seal之后快照中的key永不过期
+	if src.rangeSealed(func(k K, e sealedEntry[K, V]) bool {
+		entries = append(entries, absorbEntry[K, V]{k: k, v: e.v, persist: true})
+		return true
+	}) {
+		return entries
+	}
+	for k, n := range src.kvStore {
+		if n.rmFlag.Load() || !src.listed(n, now) {
+			continue
+		}
+		entries = append(entries, absorbEntry[K, V]{k, n.v, n.exp.Load(), expiresAt(n, now), n.persist.Load(), n.prio})
+	}
+	return entries
+}
+
+// expiresAt 返回n的过期时刻，永不过期时为零值；还没有被asyncJob计算过期时刻的节点从now开始计算
+func expiresAt[K comparable, V any](n *lruNode[K, V], now time.Time) time.Time {
+	if n.persist.Load() {
+		return time.Time{}
+	}
+	if expAt := n.expAt.Load(); !n.rearm.Load() && !expAt.IsZero() {
+		return expAt
+	}
+	return now.Add(n.exp.Load())
+}
+
+// absorb 将entries合并到dst中
+func (dst *LCache[K, V]) absorb(entries []absorbEntry[K, V], now time.Time, onConflict func(dstVal, srcVal *V) *V) error {
+	if dst.shards != nil {
+		batches := make(map[*LCache[K, V]][]absorbEntry[K, V])
+		for _, e := range entries {
+			shard := dst.shardOf(e.k)
+			batches[shard] = append(batches[shard], e)
+		}
+		var err error
+		for shard, batch := range batches {
+			if e := shard.absorb(batch, now, onConflict); e != nil {
+				err = e
+			}
+		}
+		return err
+	}
+
+	// 过期时刻的调整需要同步到过期队列，在asyncJob中完成
+	type extend struct {
+		n       *lruNode[K, V]
+		exp     time.Duration
+		expAt   time.Time
+		persist bool
+	}
+	var (
+		extended []extend
+		replaced []evicted[K, V]
+	)
+
+	dst.wlock()
+	if dst.sealed.Load() != nil {
+		dst.lock.Unlock()
+		return ErrSealed
+	}
+	for _, e := range entries {
+		n, ok := dst.kvStore[e.k]
+		// dst中的负缓存与不存在的key一样直接被覆盖
+		if !ok || !dst.listed(n, now) {
+			if dst.validate(e.v) != nil {
+				continue
+			}
+			old, ok := dst.store(e.k, e.v, func(n *lruNode[K, V]) {
+				n.exp.Store(e.exp)
+				n.persist.Store(e.persist)
+				n.prio = e.prio
+			})
+			if ok {
+				replaced = append(replaced, old)
+			}
+			// 保留src中的过期时刻，而不是从现在开始重新计时
+			if n := dst.kvStore[e.k]; n != nil && n.v == e.v && !e.persist {
+				extended = append(extended, extend{n, e.exp, e.expAt, false})
+			}
+			continue
+		}
+
+		if onConflict != nil {
+			if v := onConflict(n.v, e.v); v != n.v && dst.validate(v) == nil {
+				replaced = append(replaced, evicted[K, V]{n.k, n.v, ReasonReplaced})
+				dst.replaceValue(n, v, now)
+			}
+		}
+		if n.persist.Load() {
+			continue
+		}
+		if e.persist || e.expAt.After(expiresAt(n, now)) {
+			extended = append(extended, extend{n, e.exp, e.expAt, e.persist})
+		}
+	}
+	dst.lock.Unlock()
+
+	for _, e := range replaced {
+		dst.evict(e)
+	}
+
+	if len(extended) == 0 {
+		return nil
+	}
+	dst.runJob(func() {
+		dst.lock.Lock()
+		defer dst.lock.Unlock()
+
+		for _, e := range extended {
+			// 已经被删除的节点不需要调整
+			if e.n.prev == nil {
+				continue
+			}
+			if e.persist {
+				e.n.persist.Store(true)
+				e.n.expAt.Store(time.Time{})
+				dst.expq.remove(e.n)
+				continue
+			}
+			e.n.exp.Store(e.exp)
+			e.n.expAt.Store(e.expAt)
+			dst.expq.update(e.n)
+		}
+	})
+	return nil
+}
+
+// replaceValue 原地替换n的value，同步估算的内存占用、写入时刻以及版本号，调用方需持有写锁
+func (lc *LCache[K, V]) replaceValue(n *lruNode[K, V], v *V, now time.Time) {
+	n.v = v
+	n.lastSet = now
+	if lc.sizer != nil {
+		size := lc.sizer(v)
+		lc.stats.memory.Add(int64(size - n.size))
+		n.size = size
+	}
+	lc.bumpVersion(n)
+}
@@ -0,0 +1,120 @@
+package localcache
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLCache_Absorb(t *testing.T) {
+	newPair := func() (*LCache[string, int], *LCache[string, int]) {
+		dst := NewCache[string, int](OptWithExpire(time.Second))
+		src := NewCache[string, int](OptWithExpire(time.Second))
+
+		a, b, bb, c := 1, 2, 20, 30
+		src.Set("a", &a)
+		src.SetWithTTL("b", &b, time.Hour)
+		dst.SetWithTTL("b", &bb, time.Millisecond*100)
+		dst.Set("c", &c)
+		time.Sleep(time.Millisecond * 10)
+		return dst, src
+	}
+
+	tests := []struct {
+		name       string
+		onConflict func(dstVal, srcVal *int) *int
+		want       map[string]int
+	}{
+		{
+			name:       "keep_dst",
+			onConflict: nil,
+			want:       map[string]int{"a": 1, "b": 20, "c": 30},
+		},
+		{
+			name: "sum",
+			onConflict: func(dstVal, srcVal *int) *int {
+				n := *dstVal + *srcVal
+				return &n
+			},
+			want: map[string]int{"a": 1, "b": 22, "c": 30},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, src := newPair()
+			if err := dst.Absorb(src, tt.onConflict); err != nil {
+				t.Fatalf("Absorb() err = %v", err)
+			}
+
+			for k, want := range tt.want {
+				if v, ok := dst.Get(k); !ok || *v != want {
+					t.Errorf("Get(%q) = %v, %v, want %v, %v", k, v, ok, want, true)
+				}
+			}
+
+			// b的过期时刻取src中较晚的一个，超过dst原先的过期时间后仍然存在
+			time.Sleep(time.Millisecond * 250)
+			if _, state := dst.GetState("b"); state != StateFresh {
+				t.Errorf("GetState(%q) = %v, want %v", "b", state, StateFresh)
+			}
+		})
+	}
+}
+
+func TestLCache_AbsorbMetadata(t *testing.T) {
+	size := func(value *string) int { return len(*value) }
+	dst := NewCache[string, string](OptWithExpire(time.Second), OptWithSizeEstimator(size), OptWithValidator(func(value *string) error {
+		if *value == "" {
+			return errors.New("empty")
+		}
+		return nil
+	}))
+	defer dst.Close()
+	src := NewCache[string, string](OptWithExpire(time.Second))
+	defer src.Close()
+
+	dst.SetVal("small", strings.Repeat("x", 10))
+	src.SetVal("small", strings.Repeat("y", 1000))
+	brief := "brief"
+	src.SetWithTTL("brief", &brief, time.Millisecond*100)
+	src.SetVal("empty", "")
+	src.GetOrComputeOnce("forever", func() (*string, error) {
+		v := "forever"
+		return &v, nil
+	})
+	time.Sleep(time.Millisecond * 20)
+
+	err := dst.Absorb(src, func(dstVal, srcVal *string) *string { return srcVal })
+	if err != nil {
+		t.Fatalf("Absorb() err = %v, want nil", err)
+	}
+
+	// 替换value时同步估算的内存占用
+	if got, want := dst.Stats().MemoryBytes, int64(1000+len("brief")+len("forever")); got != want {
+		t.Errorf("Stats() MemoryBytes = %v, want %v", got, want)
+	}
+	// 未通过校验的value不会被合并
+	if dst.Contains("empty") {
+		t.Errorf("Contains(empty) = %v, want %v", true, false)
+	}
+	// dst中不存在的key保留src中剩余的过期时间
+	if ttl, _ := dst.TTL("brief"); ttl > time.Millisecond*90 {
+		t.Errorf("TTL(brief) = %v, want at most %v", ttl, time.Millisecond*90)
+	}
+	time.Sleep(time.Millisecond * 150)
+	dst.DeleteExpired()
+	if dst.Contains("brief") {
+		t.Errorf("Contains(brief) = %v, want %v", true, false)
+	}
+	// 永不过期的key合并之后仍然永不过期
+	if ttl, ok := dst.TTL("forever"); !ok || ttl != 0 {
+		t.Errorf("TTL(forever) = %v, %v, want %v, %v", ttl, ok, 0, true)
+	}
+
+	// Seal之后拒绝合并
+	dst.Seal()
+	if err := dst.Absorb(src, nil); err != ErrSealed {
+		t.Errorf("Absorb() err = %v, want %v", err, ErrSealed)
+	}
+}
@@ -0,0 +1,43 @@
+package localcache
+
+import "time"
+
+// SetNegative 写入负缓存，记录key在数据源中不存在，ttl为负缓存单独的过期时间
+// 通常比正常的过期时间短，用于在一段时间内拦截对数据源的重复查询；负缓存不经过value校验，也不会被SaveToWriter保存
+// Get读取负缓存时返回nil和true，需要区分时使用Lookup；负缓存不计入Len，也不会出现在Keys、Range、Pairs、Transform、Absorb等任何枚举中
+func (lc *LCache[K, V]) SetNegative(key K, ttl time.Duration) {
+	if lc.shards != nil {
+		shard, key := lc.route(key)
+		shard.SetNegative(key, ttl)
+		return
+	}
+	key = lc.storageKey(key)
+
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return
+	}
+	old, replaced := lc.store(key, nil, func(n *lruNode[K, V]) {
+		n.exp.Store(ttl)
+		n.negative = true
+	})
+	lc.lock.Unlock()
+
+	if replaced {
+		lc.evict(old)
+	}
+}
+
+// Lookup 与Get一样读取缓存内容并刷新过期时间，found表示key是否在缓存中，
+// negative表示命中的是SetNegative写入的负缓存，此时value为nil
+func (lc *LCache[K, V]) Lookup(key K) (value *V, found bool, negative bool) {
+	value, found = lc.get(key, func(n *lruNode[K, V]) {
+		negative = n.negative
+	})
+	// 只有负缓存的value为nil，Seal之后从快照中读取时不会调用上面的函数
+	if found && value == nil {
+		negative = true
+	}
+	return value, found, negative
+}
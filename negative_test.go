@@ -0,0 +1,107 @@
+package localcache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLCache_SetNegative(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second), OptWithCleanupInterval(time.Millisecond*20))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("hit", &n)
+	lc.SetNegative("none", time.Millisecond*100)
+
+	tests := []struct {
+		key          string
+		wantValue    *int
+		wantFound    bool
+		wantNegative bool
+	}{
+		{"hit", &n, true, false},
+		{"none", nil, true, true},
+		{"miss", nil, false, false},
+	}
+	for _, tt := range tests {
+		value, found, negative := lc.Lookup(tt.key)
+		if value != tt.wantValue || found != tt.wantFound || negative != tt.wantNegative {
+			t.Errorf("Lookup(%v) = %v, %v, %v, want %v, %v, %v", tt.key, value, found, negative, tt.wantValue, tt.wantFound, tt.wantNegative)
+		}
+	}
+	if v, ok := lc.Get("none"); !ok || v != nil {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, nil, true)
+	}
+
+	// 负缓存按自己的ttl过期，正常的key不受影响
+	time.Sleep(time.Millisecond * 250)
+	if _, found, _ := lc.Lookup("none"); found {
+		t.Errorf("Lookup() found = %v, want %v", found, false)
+	}
+	if _, found, negative := lc.Lookup("hit"); !found || negative {
+		t.Errorf("Lookup() = %v, %v, want %v, %v", found, negative, true, false)
+	}
+
+	// 写入真实的value之后不再是负缓存
+	lc.SetNegative("k", time.Second)
+	lc.Set("k", &n)
+	if value, found, negative := lc.Lookup("k"); value != &n || !found || negative {
+		t.Errorf("Lookup() = %v, %v, %v, want %v, %v, %v", value, found, negative, &n, true, false)
+	}
+}
+
+func TestLCache_NegativeEnumeration(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+	since := time.Now().Add(-time.Second)
+	lc.SetVal("hit", 1)
+	lc.SetNegative("none", time.Second)
+
+	// 负缓存不出现在任何枚举中，也不会把nil传给用户的函数
+	if got := lc.Len(); got != 1 {
+		t.Errorf("Len() = %v, want %v", got, 1)
+	}
+	if got := lc.Keys(); !reflect.DeepEqual(got, []string{"hit"}) {
+		t.Errorf("Keys() = %v, want %v", got, []string{"hit"})
+	}
+	if keys, _ := lc.Pairs(); !reflect.DeepEqual(keys, []string{"hit"}) {
+		t.Errorf("Pairs() keys = %v, want %v", keys, []string{"hit"})
+	}
+	if got := lc.EntriesModifiedSince(since); len(got) != 1 || got[0].Key != "hit" {
+		t.Errorf("EntriesModifiedSince() = %v, want only %v", got, "hit")
+	}
+	lc.Range(func(key string, value *int) bool {
+		if value == nil {
+			t.Errorf("Range() key %v value = nil", key)
+		}
+		return true
+	})
+	lc.Transform(func(key string, value *int) (*int, bool) {
+		v := *value * 2
+		return &v, true
+	})
+	if v, ok := lc.GetVal("hit"); !ok || v != 2 {
+		t.Errorf("GetVal() = %v, %v, want %v, %v", v, ok, 2, true)
+	}
+
+	other := NewCache[string, int](OptWithExpire(time.Second))
+	defer other.Close()
+	other.SetVal("hit", 2)
+	if lc.Fingerprint(fnv64) != other.Fingerprint(fnv64) {
+		t.Errorf("Fingerprint() differs with a negative entry")
+	}
+
+	// src中的负缓存不会被合并，dst中的负缓存被src的value覆盖
+	other.SetNegative("absent", time.Second)
+	other.SetVal("none", 3)
+	if err := lc.Absorb(other, nil); err != nil {
+		t.Fatalf("Absorb() error = %v", err)
+	}
+	if _, found, _ := lc.Lookup("absent"); found {
+		t.Errorf("Lookup(absent) found = %v, want %v", found, false)
+	}
+	if v, found, negative := lc.Lookup("none"); !found || negative || *v != 3 {
+		t.Errorf("Lookup(none) = %v, %v, %v, want %v, %v, %v", v, found, negative, 3, true, false)
+	}
+}
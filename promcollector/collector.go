@@ -0,0 +1,64 @@
+// Package promcollector 将localcache的统计信息导出为Prometheus指标
+// 独立为一个module，只有需要Prometheus的使用者才会引入client_golang依赖
+package promcollector
+
+import (
+	"github.com/nobugtodebug/localcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Source 提供统计信息的缓存，任意类型参数的*localcache.LCache都满足
+type Source interface {
+	Stats() localcache.CacheStats
+	Len() int
+}
+
+// Collector 每次采集时从缓存的原子计数器读取统计信息
+type Collector struct {
+	cache Source
+
+	size        *prometheus.Desc
+	memory      *prometheus.Desc
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+}
+
+// New 创建cache的Collector，labels作为固定标签附加到所有指标上，例如prometheus.Labels{"cache": "users"}
+// 同一个Registry中注册多个缓存时，需要用labels区分
+func New(cache Source, labels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("localcache", "", name), help, nil, labels)
+	}
+	return &Collector{
+		cache:       cache,
+		size:        desc("size", "Number of live keys in the cache."),
+		memory:      desc("memory_bytes", "Estimated memory used by the cache."),
+		hits:        desc("hits_total", "Number of Get calls that found the key."),
+		misses:      desc("misses_total", "Number of Get calls that missed the key."),
+		evictions:   desc("evictions_total", "Number of keys evicted for capacity."),
+		expirations: desc("expirations_total", "Number of keys removed after expiring."),
+	}
+}
+
+// Describe 实现prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.size
+	ch <- c.memory
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+}
+
+// Collect 实现prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(c.cache.Len()))
+	ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(s.MemoryBytes))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(s.Expirations))
+}
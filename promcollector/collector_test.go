@@ -0,0 +1,50 @@
+package promcollector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nobugtodebug/localcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	lc := localcache.NewCache[string, int](localcache.OptWithExpire(time.Second))
+	defer lc.Close()
+
+	n := 1
+	lc.Set("a", &n)
+	lc.Set("b", &n)
+	lc.Get("a")
+	lc.Get("c")
+
+	c := New(lc, prometheus.Labels{"cache": "test"})
+	want := `
+# HELP localcache_evictions_total Number of keys evicted for capacity.
+# TYPE localcache_evictions_total counter
+localcache_evictions_total{cache="test"} 0
+# HELP localcache_expirations_total Number of keys removed after expiring.
+# TYPE localcache_expirations_total counter
+localcache_expirations_total{cache="test"} 0
+# HELP localcache_hits_total Number of Get calls that found the key.
+# TYPE localcache_hits_total counter
+localcache_hits_total{cache="test"} 1
+# HELP localcache_memory_bytes Estimated memory used by the cache.
+# TYPE localcache_memory_bytes gauge
+localcache_memory_bytes{cache="test"} 0
+# HELP localcache_misses_total Number of Get calls that missed the key.
+# TYPE localcache_misses_total counter
+localcache_misses_total{cache="test"} 1
+# HELP localcache_size Number of live keys in the cache.
+# TYPE localcache_size gauge
+localcache_size{cache="test"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Errorf("CollectAndCompare() err = %v", err)
+	}
+	if got := testutil.CollectAndCount(c); got != 6 {
+		t.Errorf("CollectAndCount() = %v, want %v", got, 6)
+	}
+}
@@ -0,0 +1,129 @@
+package localcache
+
+import "time"
+
+// SnapshotBuilder 在后台重建整个缓存的内容
+// Commit之前，对缓存的读写仍然作用于当前的数据；Commit时新数据一次性替换掉当前的全部数据
+type SnapshotBuilder[K comparable, V any] struct {
+	lc        *LCache[K, V]
+	nodes     map[K]*lruNode[K, V]
+	order     []*lruNode[K, V] // 写入顺序，Commit后最后写入的key位于lru表头
+	committed bool
+}
+
+// BeginRebuild 开始重建缓存，返回用于写入新数据的SnapshotBuilder
+func (lc *LCache[K, V]) BeginRebuild() *SnapshotBuilder[K, V] {
+	return &SnapshotBuilder[K, V]{
+		lc:    lc,
+		nodes: make(map[K]*lruNode[K, V]),
+	}
+}
+
+// Set 向新数据中写入key，使用缓存默认的过期时间
+// SnapshotBuilder不是并发安全的，同一个builder只能在一个goroutine中使用
+func (b *SnapshotBuilder[K, V]) Set(key K, value *V) {
+	key = b.lc.storageKey(key)
+	// 分片时由key所在的分片校验，被拒绝的value计入分片的统计
+	target := b.lc
+	if target.shards != nil {
+		target = target.shardOf(key)
+	}
+	if target.validate(value) != nil {
+		return
+	}
+
+	n, ok := b.nodes[key]
+	if !ok {
+		n = &lruNode[K, V]{
+			k: key,
+		}
+		b.nodes[key] = n
+		b.order = append(b.order, n)
+	}
+	n.v = value
+	n.exp.Store(b.lc.o.exp)
+	n.lastSet = time.Now()
+	if !ok {
+		n.created = n.lastSet
+	}
+	if b.lc.sizer != nil {
+		n.size = b.lc.sizer(value)
+	}
+}
+
+// Commit 用新数据原子地替换缓存的当前数据，重复调用时不做任何事
+// 设置了OptWithShards时逐个分片替换，每个分片的替换是原子的
+func (b *SnapshotBuilder[K, V]) Commit() {
+	if b.committed {
+		return
+	}
+	b.committed = true
+	if b.lc.shards == nil {
+		b.lc.runJob(func() {
+			b.lc.applyRebuild(b)
+		})
+		return
+	}
+
+	// 按分片拆分新数据，保持写入顺序
+	builders := make(map[*LCache[K, V]]*SnapshotBuilder[K, V], len(b.lc.shards))
+	for _, shard := range b.lc.shards {
+		builders[shard] = shard.BeginRebuild()
+	}
+	for _, n := range b.order {
+		sb := builders[b.lc.shardOf(n.k)]
+		sb.nodes[n.k] = n
+		sb.order = append(sb.order, n)
+	}
+	b.nodes = nil
+	b.order = nil
+	for _, sb := range builders {
+		sb.Commit()
+	}
+}
+
+// applyRebuild 在asyncJob中替换数据以及lru链表
+func (lc *LCache[K, V]) applyRebuild(b *SnapshotBuilder[K, V]) {
+	var (
+		removed  []*lruNode[K, V]
+		replaced []evicted[K, V]
+	)
+	lc.lock.Lock()
+	for k, n := range lc.kvStore {
+		n.rmFlag.Store(true)
+		if nn, ok := b.nodes[k]; !ok {
+			removed = append(removed, n)
+		} else if nn.v != n.v {
+			replaced = append(replaced, evicted[K, V]{k, n.v, ReasonReplaced})
+		}
+	}
+	lc.kvStore = b.nodes
+	lc.dependents = make(map[K]map[K]struct{})
+	lc.ghosts = make(map[K]weakRef[V])
+	lc.keyCounter = len(b.nodes)
+	var memory int64
+	for _, n := range b.order {
+		memory += int64(n.size)
+	}
+	lc.stats.memory.Store(memory)
+
+	lc.resetList()
+	expAt := time.Now()
+	for _, n := range b.order {
+		lc.bumpVersion(n)
+		n.expAt.Store(expAt.Add(n.exp.Load()))
+		lc.linkHead(n)
+		lc.expq.update(n)
+	}
+	lc.lock.Unlock()
+
+	b.nodes = nil
+	b.order = nil
+
+	lc.notifyRemoved(ReasonReplaced, removed...)
+	for _, e := range replaced {
+		e := e
+		lc.queueCall(func() { lc.evict(e) })
+	}
+	lc.evictOverflow()
+}
@@ -0,0 +1,98 @@
+package localcache
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSnapshotBuilder_Commit(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	defer lc.Close()
+
+	const keys = 100
+	oldVal, newVal := 1, 2
+	for i := 0; i < keys; i++ {
+		lc.Set(fmt.Sprintf("k%d", i), &oldVal)
+	}
+	lc.Set("stale", &oldVal)
+
+	var committing atomic.Bool
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				// Commit之前只能读到完整的旧数据，读取之后再检查，保证读取时Commit还没有开始
+				v, ok := lc.Get(fmt.Sprintf("k%d", i%keys))
+				before := !committing.Load()
+				if before && (!ok || *v != oldVal) {
+					t.Errorf("Get() before Commit = %v, %v, want %v, %v", v, ok, oldVal, true)
+					return
+				}
+				// 让出CPU，CPU较少时不会饿死写入新数据的goroutine
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	b := lc.BeginRebuild()
+	for i := 0; i < keys; i++ {
+		b.Set(fmt.Sprintf("k%d", i), &newVal)
+	}
+	committing.Store(true)
+	b.Commit()
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < keys; i++ {
+		if v, ok := lc.Get(fmt.Sprintf("k%d", i)); !ok || *v != newVal {
+			t.Fatalf("Get() after Commit = %v, %v, want %v, %v", v, ok, newVal, true)
+		}
+	}
+	if _, ok := lc.Get("stale"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+}
+
+func TestSnapshotBuilder_CommitReplaced(t *testing.T) {
+	var replaced []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Minute),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			if reason == ReasonReplaced {
+				replaced = append(replaced, key)
+			}
+		}),
+	)
+
+	v1, v2 := 1, 2
+	for _, k := range []string{"a", "b", "c"} {
+		lc.Set(k, &v1)
+	}
+	b := lc.BeginRebuild()
+	for _, k := range []string{"a", "b", "c"} {
+		b.Set(k, &v2)
+	}
+	b.Commit()
+	lc.Close()
+
+	// 每个被替换的key各触发一次回调
+	sort.Strings(replaced)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(replaced, want) {
+		t.Errorf("OnEvict() replaced = %v, want %v", replaced, want)
+	}
+}
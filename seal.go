@@ -0,0 +1,88 @@
+package localcache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSealed 缓存已经Seal，不再接受写入
+var ErrSealed = errors.New("localcache: cache is sealed")
+
+// sealedEntry Seal之后只读快照中的一个key
+type sealedEntry[K comparable, V any] struct {
+	v        *V
+	lastSet  time.Time
+	created  time.Time
+	negative bool
+}
+
+// Seal 将缓存降级为只读：处理完积压的更新后停止asyncJob，之后Get无锁地读取Seal时的只读快照
+// Seal时已经过期的key不会进入快照，快照中的key之后也不再过期；Seal之后TrySet返回ErrSealed，其他写入和删除不做任何事
+// 取得快照之后map、lru链表、依赖索引等只用于写入的结构都会被释放，Len、Keys、Range等读取方法都从快照中读取
+// 适用于预热后只读的参考数据
+func (lc *LCache[K, V]) Seal() {
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			shard.Seal()
+		}
+		return
+	}
+	lc.Close()
+
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+	if lc.sealed.Load() != nil {
+		return
+	}
+
+	now := time.Now()
+	snapshot := make(map[K]sealedEntry[K, V], len(lc.kvStore))
+	for k, n := range lc.kvStore {
+		if !lc.expired(n, now) {
+			snapshot[k] = sealedEntry[K, V]{n.v, n.lastSet, n.created, n.negative}
+		}
+	}
+	lc.sealed.Store(&snapshot)
+
+	// asyncJob已经退出，直接释放写入用的结构
+	lc.resetList()
+	lc.kvStore = make(map[K]*lruNode[K, V])
+	lc.dependents = make(map[K]map[K]struct{})
+	lc.ghosts = make(map[K]weakRef[V])
+	lc.pending = nil
+	lc.hasPending.Store(false)
+	lc.keyCounter = 0
+}
+
+// getSealed 从Seal之后的只读快照中读取key，缓存没有Seal时sealed为false
+func (lc *LCache[K, V]) getSealed(key K) (value *V, sealed bool, ok bool) {
+	m := lc.sealed.Load()
+	if m == nil {
+		return nil, false, false
+	}
+	e, ok := (*m)[key]
+	return e.v, true, ok
+}
+
+// rangeSealed 对Seal之后只读快照中除负缓存以外的每个key调用fn，fn返回false时停止；缓存没有Seal时返回false
+// 需要在持有锁时调用，Seal与释放map在同一次写锁内完成，持有锁时检查不会读到已经释放的map
+func (lc *LCache[K, V]) rangeSealed(fn func(key K, e sealedEntry[K, V]) bool) bool {
+	m := lc.sealed.Load()
+	if m == nil {
+		return false
+	}
+	for k, e := range *m {
+		if e.negative {
+			continue
+		}
+		if !fn(k, e) {
+			break
+		}
+	}
+	return true
+}
+
+// entry 返回快照中的key对应的Entry，快照中的key永不过期
+func (e sealedEntry[K, V]) entry(key K) Entry[K, V] {
+	return Entry[K, V]{Key: key, Value: e.v, LastSet: e.lastSet, CreatedAt: e.created}
+}
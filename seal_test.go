@@ -0,0 +1,126 @@
+package localcache
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLCache_Seal(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Second))
+	a, b := 1, 2
+	lc.Set("a", &a)
+	lc.Set("b", &b)
+	lc.Seal()
+	lc.Seal()
+
+	if v, ok := lc.Get("a"); !ok || *v != 1 {
+		t.Errorf("Get() = %v, %v, want 1, true", v, ok)
+	}
+	if _, ok := lc.Get("c"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+
+	c := 3
+	if err := lc.TrySet("c", &c); !errors.Is(err, ErrSealed) {
+		t.Errorf("TrySet() err = %v, want %v", err, ErrSealed)
+	}
+	lc.Set("a", &c)
+	lc.Del("b")
+	if v, ok := lc.Get("a"); !ok || *v != 1 {
+		t.Errorf("Get() = %v, %v, want 1, true", v, ok)
+	}
+	if _, ok := lc.Get("b"); !ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, true)
+	}
+}
+
+func TestLCache_SealReadOnly(t *testing.T) {
+	lc := NewCache[string, int](OptWithExpire(time.Millisecond * 50))
+	lc.SetVal("a", 1)
+	b := 2
+	lc.SetWithDeps("b", &b, "a")
+	lc.SetNegative("none", time.Second)
+	lc.Seal()
+
+	// 写入用的结构已经释放
+	if len(lc.kvStore) != 0 || lc.lruLen != 0 || len(lc.dependents) != 0 {
+		t.Errorf("after Seal kvStore = %d, lruLen = %d, dependents = %d, want all 0", len(lc.kvStore), lc.lruLen, len(lc.dependents))
+	}
+
+	// 超过原来的过期时间之后，所有读取方法仍然一致地从快照中读取
+	time.Sleep(time.Millisecond * 100)
+	want := []string{"a", "b"}
+	keys := lc.Keys()
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys() = %v, want %v", keys, want)
+	}
+	if got := lc.Len(); got != 2 {
+		t.Errorf("Len() = %v, want %v", got, 2)
+	}
+	if pk, _ := lc.Pairs(); len(pk) != 2 {
+		t.Errorf("Pairs() keys = %v, want %v", pk, want)
+	}
+	ranged := 0
+	lc.Range(func(key string, value *int) bool {
+		ranged++
+		return true
+	})
+	if ranged != 2 {
+		t.Errorf("Range() visited %v keys, want %v", ranged, 2)
+	}
+	if got := lc.EntriesModifiedSince(time.Time{}); len(got) != 2 {
+		t.Errorf("EntriesModifiedSince() = %v, want %v entries", got, 2)
+	}
+	if got := lc.Snapshot(); len(got) != 2 {
+		t.Errorf("Snapshot() = %v, want %v entries", got, 2)
+	}
+	if got := lc.StaleKeys(); len(got) != 0 {
+		t.Errorf("StaleKeys() = %v, want empty", got)
+	}
+	if got := lc.ShardSizes(); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("ShardSizes() = %v, want %v", got, []int{3})
+	}
+	if _, state := lc.GetState("a"); state != StateFresh {
+		t.Errorf("GetState() = %v, want %v", state, StateFresh)
+	}
+	if !lc.Contains("b") {
+		t.Errorf("Contains() = %v, want %v", false, true)
+	}
+	if v, found, negative := lc.Lookup("none"); !found || !negative || v != nil {
+		t.Errorf("Lookup() = %v, %v, %v, want %v, %v, %v", v, found, negative, nil, true, true)
+	}
+}
+
+func benchmarkGet(b *testing.B, seal bool) {
+	lc := NewCache[int, int](OptWithExpire(time.Minute))
+	for i := 0; i < 1024; i++ {
+		v := i
+		lc.Set(i, &v)
+	}
+	if seal {
+		lc.Seal()
+	} else {
+		defer lc.Close()
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			lc.Get(i & 1023)
+			i++
+		}
+	})
+}
+
+func BenchmarkLCache_Get_Normal(b *testing.B) {
+	benchmarkGet(b, false)
+}
+
+func BenchmarkLCache_Get_Sealed(b *testing.B) {
+	benchmarkGet(b, true)
+}
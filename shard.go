@@ -0,0 +1,98 @@
+package localcache
+
+import "time"
+
+// OptWithShards 将缓存拆分为n个分片，每个分片有独立的map、lru链表、锁以及asyncJob，减少并发读写时的锁竞争
+// key按hash分配到分片，OptWithMaxKeys、OptWithMaxMemory等容量限制平均分配给每个分片，lru淘汰只在分片内进行；
+// SetWithDeps声明的依赖只在同一分片内生效，Commit、Seal等整体操作逐个分片完成。n不大于1时不分片
+func OptWithShards(n int) Option {
+	return func(co *CacheOptions) {
+		co.shards = n
+	}
+}
+
+// OptWithKeyHasher 设置分片路由使用的hash函数，未设置时整数和字符串类型的key使用内置的快速实现，
+// 其他类型通过反射按==的语义逐个字段计算(指针按地址)。key中已经带有预先计算好的hash时，可以直接返回它以省去每次读写的hash计算
+func OptWithKeyHasher[K comparable](fn func(key K) uint64) Option {
+	return func(co *CacheOptions) {
+		co.keyHasher = fn
+	}
+}
+
+// newShardedCache 创建负责路由的缓存以及它的各个分片
+func newShardedCache[K comparable, V any](o *CacheOptions) *LCache[K, V] {
+	lc := &LCache[K, V]{}
+	lc.init(o)
+	lc.hash, _ = o.keyHasher.(func(K) uint64)
+	if lc.hash == nil {
+		lc.hash = keyHasher[K]()
+	}
+	// 负责路由的缓存没有asyncJob
+	lc.exited = make(chan struct{})
+	close(lc.exited)
+
+	so := *o
+	so.shards = 0
+	// key在路由之前已经转换过，分片中不再转换
+	so.keyTransform = nil
+	so.max = divCeil(o.max, o.shards)
+	so.maxMemory = divCeil(o.maxMemory, o.shards)
+	so.missTrack = divCeil(o.missTrack, o.shards)
+	lc.shards = make([]*LCache[K, V], o.shards)
+	for i := range lc.shards {
+		lc.shards[i] = newCache[K, V](&so)
+	}
+	return lc
+}
+
+// divCeil 向上取整的除法，用于平均分配容量限制
+func divCeil(a, b int) int {
+	if a <= 0 {
+		return a
+	}
+	return (a + b - 1) / b
+}
+
+// route 转换key并返回它所在的分片，只在设置了OptWithShards时调用
+func (lc *LCache[K, V]) route(key K) (*LCache[K, V], K) {
+	key = lc.storageKey(key)
+	return lc.shardOf(key), key
+}
+
+// shardOf 返回已经转换过的key所在的分片
+func (lc *LCache[K, V]) shardOf(key K) *LCache[K, V] {
+	return lc.shards[lc.hash(key)%uint64(len(lc.shards))]
+}
+
+// merge 累加一个分片的统计信息，加锁等待的平均值按采样数加权，最大值取较大者
+func (s *CacheStats) merge(o CacheStats) {
+	if samples := s.LockWaitSamples + o.LockWaitSamples; samples > 0 {
+		avg := func(a, b time.Duration) time.Duration {
+			return time.Duration((uint64(a)*s.LockWaitSamples + uint64(b)*o.LockWaitSamples) / samples)
+		}
+		s.ReadLockWaitAvg = avg(s.ReadLockWaitAvg, o.ReadLockWaitAvg)
+		s.WriteLockWaitAvg = avg(s.WriteLockWaitAvg, o.WriteLockWaitAvg)
+		s.LockWaitSamples = samples
+	}
+	if o.ReadLockWaitMax > s.ReadLockWaitMax {
+		s.ReadLockWaitMax = o.ReadLockWaitMax
+	}
+	if o.WriteLockWaitMax > s.WriteLockWaitMax {
+		s.WriteLockWaitMax = o.WriteLockWaitMax
+	}
+
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Sets += o.Sets
+	s.Evictions += o.Evictions
+	s.Expirations += o.Expirations
+	s.UnreadExpirations += o.UnreadExpirations
+	s.Repairs += o.Repairs
+	s.ChannelDepth += o.ChannelDepth
+	s.AsyncErrors += o.AsyncErrors
+	s.MemoryBytes += o.MemoryBytes
+	s.SoftHits += o.SoftHits
+	s.DroppedUpdates += o.DroppedUpdates
+	s.DroppedEvents += o.DroppedEvents
+	s.Rejected += o.Rejected
+}
@@ -0,0 +1,172 @@
+package localcache
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLCache_Shards(t *testing.T) {
+	lc := NewCache[string, int](
+		OptWithExpire(time.Second),
+		OptWithShards(4),
+		OptWithKeyTransform(strings.ToLower),
+	)
+	defer lc.Close()
+
+	for i := 0; i < 100; i++ {
+		i := i
+		lc.Set("K"+strconv.Itoa(i), &i)
+	}
+	time.Sleep(time.Millisecond * 10)
+
+	// 转换后的key决定所在的分片
+	if v, ok := lc.Get("k42"); !ok || *v != 42 {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, 42, true)
+	}
+	if got := lc.Len(); got != 100 {
+		t.Errorf("Len() = %v, want %v", got, 100)
+	}
+	sizes := lc.ShardSizes()
+	if len(sizes) != 4 {
+		t.Fatalf("len(ShardSizes()) = %v, want %v", len(sizes), 4)
+	}
+	total := 0
+	for i, n := range sizes {
+		if n == 0 {
+			t.Errorf("ShardSizes()[%d] = 0, want > 0", i)
+		}
+		total += n
+	}
+	if total != 100 {
+		t.Errorf("sum(ShardSizes()) = %v, want %v", total, 100)
+	}
+	if got := len(lc.Keys()); got != 100 {
+		t.Errorf("len(Keys()) = %v, want %v", got, 100)
+	}
+
+	got := lc.GetMany([]string{"K1", "k2", "missing"})
+	if len(got) != 2 || *got["K1"] != 1 || *got["k2"] != 2 {
+		t.Errorf("GetMany() = %v, want K1 and k2", got)
+	}
+
+	lc.Del("K42")
+	if _, ok := lc.Get("k42"); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+	stats := lc.Stats()
+	if stats.Sets != 100 || stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("Stats() Sets, Hits, Misses = %v, %v, %v, want %v, %v, %v",
+			stats.Sets, stats.Hits, stats.Misses, 100, 3, 2)
+	}
+	for i, shard := range lc.shards {
+		if err := shard.checkInvariants(); err != nil {
+			t.Errorf("shard %d checkInvariants() err = %v, want nil", i, err)
+		}
+	}
+}
+
+func TestLCache_ShardsPointerKeys(t *testing.T) {
+	lc := NewCache[*testKey, int](OptWithExpire(time.Second), OptWithShards(16))
+	defer lc.Close()
+
+	keys := make([]*testKey, 50)
+	for i := range keys {
+		keys[i] = &testKey{i, "before"}
+		lc.SetVal(keys[i], i)
+	}
+	// 分片按指针的地址路由，修改指向的内容不影响key所在的分片
+	for _, k := range keys {
+		k.name = "after"
+	}
+	for i, k := range keys {
+		if v, ok := lc.Get(k); !ok || *v != i {
+			t.Errorf("Get(%d) = %v, %v, want %v, %v", i, v, ok, i, true)
+		}
+	}
+
+	floats := NewCache[float64, int](OptWithExpire(time.Second), OptWithShards(16))
+	defer floats.Close()
+	floats.SetVal(math.Copysign(0, -1), 1)
+	if v, ok := floats.Get(0); !ok || *v != 1 {
+		t.Errorf("Get(0) = %v, %v, want %v, %v", v, ok, 1, true)
+	}
+}
+
+func TestLCache_ShardsMaxKeys(t *testing.T) {
+	lc := NewCache[int, int](OptWithExpire(time.Second), OptWithShards(4), OptWithMaxKeys(40))
+	defer lc.Close()
+
+	for i := 0; i < 1000; i++ {
+		i := i
+		lc.Set(i, &i)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	// 每个分片最多保存10个key
+	for i, n := range lc.ShardSizes() {
+		if n > 10 {
+			t.Errorf("ShardSizes()[%d] = %v, want <= %v", i, n, 10)
+		}
+	}
+}
+
+func benchmarkContention(b *testing.B, opts ...Option) {
+	lc := NewCache[int, int](append([]Option{OptWithExpire(time.Minute)}, opts...)...)
+	defer lc.Close()
+	for i := 0; i < 1024; i++ {
+		n := i
+		lc.Set(i, &n)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := i & 1023
+			if i%4 == 0 {
+				lc.Set(k, &k)
+			} else {
+				lc.Get(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkLCache_ContentionSingle(b *testing.B) {
+	benchmarkContention(b)
+}
+
+func BenchmarkLCache_ContentionShards(b *testing.B) {
+	benchmarkContention(b, OptWithShards(16))
+}
+
+// benchmarkKeyHasher 分片缓存以字符串为key的读取，opts决定分片路由使用的hash函数
+func benchmarkKeyHasher(b *testing.B, opts ...Option) {
+	lc := NewCache[string, int](append(opts, OptWithExpire(time.Minute), OptWithShards(16))...)
+	defer lc.Close()
+
+	value := 1
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:session:%08d:profile", i)
+		lc.Set(keys[i], &value)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lc.Get(keys[i&1023])
+	}
+}
+
+func BenchmarkLCache_KeyHasherDefault(b *testing.B) {
+	benchmarkKeyHasher(b)
+}
+
+func BenchmarkLCache_KeyHasherCustom(b *testing.B) {
+	benchmarkKeyHasher(b, OptWithKeyHasher(fnv64a))
+}
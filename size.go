@@ -0,0 +1,228 @@
+package localcache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// defaultEntryOverhead 每个key固定的额外开销：lru节点、map中的槽位等
+	defaultEntryOverhead = int(unsafe.Sizeof(lruNode[struct{}, struct{}]{})) + 16
+	// defaultPointerBytes 无法继续估算的指针、函数、channel等按一个指针的大小计算
+	defaultPointerBytes = int(unsafe.Sizeof(uintptr(0)))
+	// defaultMaxDepth 反射遍历的最大深度，更深的部分按指针大小计算
+	defaultMaxDepth = 16
+)
+
+// ReflectSizer 基于反射估算value占用的内存字节数，是未设置OptWithSizeEstimator时的默认估算方式
+// 对于函数、channel、unsafe.Pointer以及超过遍历深度、重复引用的指针，只按PointerBytes计算，任何类型都不会panic
+type ReflectSizer struct {
+	EntryOverhead int // 每个key固定的额外开销
+	PointerBytes  int // 无法继续估算的部分按多少字节计算
+	MaxDepth      int // 反射遍历的最大深度
+}
+
+// DefaultReflectSizer 默认参数的ReflectSizer
+var DefaultReflectSizer = ReflectSizer{
+	EntryOverhead: defaultEntryOverhead,
+	PointerBytes:  defaultPointerBytes,
+	MaxDepth:      defaultMaxDepth,
+}
+
+// Size 估算v的大小，包括EntryOverhead
+func (s ReflectSizer) Size(v any) (size int) {
+	defer func() {
+		// 兜底，估算失败时只计算固定开销
+		if r := recover(); r != nil {
+			size = s.EntryOverhead + s.PointerBytes
+		}
+	}()
+
+	w := sizeWalker{s: s, seen: make(map[uintptr]struct{})}
+	return s.EntryOverhead + w.walk(reflect.ValueOf(v), 0)
+}
+
+type sizeWalker struct {
+	s    ReflectSizer
+	seen map[uintptr]struct{} // 已经计算过的指针，避免重复计算以及循环引用
+}
+
+// walk 返回v本身以及它引用的内存的大小
+func (w *sizeWalker) walk(v reflect.Value, depth int) int {
+	if !v.IsValid() {
+		return 0
+	}
+	if depth > w.s.MaxDepth {
+		return w.s.PointerBytes
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return w.s.PointerBytes
+		}
+		return w.s.PointerBytes + w.walk(v.Elem(), depth+1)
+	case reflect.Interface:
+		if v.IsNil() {
+			return int(v.Type().Size())
+		}
+		return int(v.Type().Size()) + w.walk(v.Elem(), depth+1)
+	case reflect.String:
+		return int(v.Type().Size()) + v.Len()
+	case reflect.Slice:
+		size := int(v.Type().Size())
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return size
+		}
+		if elem := v.Type().Elem(); !hasRefs(elem) {
+			return size + v.Cap()*int(elem.Size())
+		}
+		for i := 0; i < v.Len(); i++ {
+			size += w.walk(v.Index(i), depth+1)
+		}
+		// 未使用的容量
+		return size + (v.Cap()-v.Len())*int(v.Type().Elem().Size())
+	case reflect.Array:
+		if !hasRefs(v.Type()) {
+			return int(v.Type().Size())
+		}
+		size := 0
+		for i := 0; i < v.Len(); i++ {
+			size += w.walk(v.Index(i), depth+1)
+		}
+		return size
+	case reflect.Map:
+		size := int(v.Type().Size())
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return size
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			size += w.walk(iter.Key(), depth+1) + w.walk(iter.Value(), depth+1)
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < v.NumField(); i++ {
+			size += w.walk(v.Field(i), depth+1)
+		}
+		// 字段对齐产生的填充
+		if padding := int(v.Type().Size()) - w.flatSize(v.Type()); padding > 0 {
+			size += padding
+		}
+		return size
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return w.s.PointerBytes
+	default:
+		return int(v.Type().Size())
+	}
+}
+
+// visit 记录指针p，p已经计算过时返回false
+func (w *sizeWalker) visit(p uintptr) bool {
+	if _, ok := w.seen[p]; ok {
+		return false
+	}
+	w.seen[p] = struct{}{}
+	return true
+}
+
+// hasRefs 返回t是否包含指针、字符串、切片等引用了其他内存的部分
+// 不包含引用的类型可以直接用Type.Size计算，不需要逐个元素遍历
+func hasRefs(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() > 0 && hasRefs(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasRefs(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Pointer, reflect.Interface, reflect.String, reflect.Slice, reflect.Map,
+		reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	default:
+		return false
+	}
+}
+
+// flatSize 结构体各字段自身大小之和，不含对齐填充
+func (w *sizeWalker) flatSize(t reflect.Type) int {
+	size := 0
+	for i := 0; i < t.NumField(); i++ {
+		size += int(t.Field(i).Type.Size())
+	}
+	return size
+}
+
+// memoryUnits OptWithMaxMemory支持的单位，按后缀从长到短匹配
+var memoryUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseMemory 解析"1.5GB"、"512 mb"、"4096"这样的内存大小，返回字节数
+func parseMemory(s string) (int, error) {
+	num := strings.ToUpper(strings.TrimSpace(s))
+	unit := 1.0
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num = strings.TrimSpace(strings.TrimSuffix(num, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+	if num == "" || strings.Trim(num, "0123456789.") != "" {
+		return 0, fmt.Errorf("localcache: invalid max memory %q", s)
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("localcache: invalid max memory %q", s)
+	}
+	bytes := n * unit
+	if bytes >= math.MaxInt {
+		return 0, fmt.Errorf("localcache: max memory %q overflows", s)
+	}
+	return int(bytes), nil
+}
+
+// ErrEntryTooLarge value的估算大小超过OptWithMaxEntrySize的上限
+var ErrEntryTooLarge = errors.New("localcache: entry too large")
+
+// OptWithMaxEntrySize 设置单个value估算大小的上限(字节)，超过上限的value不会被写入，计入Stats().Rejected，TrySet返回ErrEntryTooLarge
+// 大小由OptWithSizeEstimator或OptWithSizer估算，都未设置时使用DefaultReflectSizer；bytes不大于0时不限制
+func OptWithMaxEntrySize(bytes int) Option {
+	return func(co *CacheOptions) {
+		co.maxEntrySize = bytes
+	}
+}
+
+// validate 写入前校验value，拒绝nil，再依次检查OptWithValidator以及OptWithMaxEntrySize
+func (lc *LCache[K, V]) validate(value *V) error {
+	if value == nil {
+		return ErrNilValue
+	}
+	if lc.validator != nil {
+		if err := lc.validator(value); err != nil {
+			return err
+		}
+	}
+	if lc.o.maxEntrySize > 0 && lc.sizer != nil && lc.sizer(value) > lc.o.maxEntrySize {
+		lc.stats.rejected.Add(1)
+		return ErrEntryTooLarge
+	}
+	return nil
+}
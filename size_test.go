@@ -0,0 +1,181 @@
+package localcache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type sizeTestValue struct {
+	Name   string
+	Any    any
+	Tags   map[string]int
+	Fn     func()
+	Ch     chan int
+	secret []byte
+	Next   *sizeTestValue
+}
+
+func TestReflectSizer_Size(t *testing.T) {
+	s := DefaultReflectSizer
+	base := s.Size(&sizeTestValue{})
+
+	loop := &sizeTestValue{Name: "loop"}
+	loop.Next = loop
+
+	tests := []struct {
+		name  string
+		value any
+		min   int
+		max   int
+	}{
+		{"nil", nil, s.EntryOverhead, s.EntryOverhead},
+		{"int", 1, s.EntryOverhead + 8, s.EntryOverhead + 8},
+		{"string", "hello", s.EntryOverhead + 5, s.EntryOverhead + 32},
+		{"bytes", make([]byte, 1000), s.EntryOverhead + 1000, s.EntryOverhead + 1100},
+		{"empty_struct", &sizeTestValue{}, base, base},
+		{"interface_field", &sizeTestValue{Any: "0123456789"}, base + 10, base + 64},
+		{"map_field", &sizeTestValue{Tags: map[string]int{"a": 1, "bb": 2}}, base + 3 + 16, base + 256},
+		{"func_chan_fields", &sizeTestValue{Fn: func() {}, Ch: make(chan int)}, base, base},
+		{"unexported_field", &sizeTestValue{secret: make([]byte, 100)}, base + 100, base + 128},
+		{"cycle", loop, base + 4, base + 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Size(tt.value)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Size() = %v, want in [%v, %v]", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestLCache_MemoryBytes(t *testing.T) {
+	lc := NewCache[string, string](
+		OptWithExpire(time.Second),
+		OptWithSizeEstimator(func(value *string) int {
+			return 100 + len(*value)
+		}),
+	)
+
+	for i := 0; i < 10; i++ {
+		v := fmt.Sprintf("%010d", i)
+		lc.Set(fmt.Sprintf("k%d", i), &v)
+	}
+	if got := lc.Stats().MemoryBytes; got != 10*110 {
+		t.Errorf("Stats() MemoryBytes = %v, want %v", got, 10*110)
+	}
+
+	// 覆盖写只计算差值，删除时扣除
+	long := "01234567890123456789"
+	lc.Set("k0", &long)
+	lc.Del("k1")
+	if got := lc.Stats().MemoryBytes; got != 9*110+10 {
+		t.Errorf("Stats() MemoryBytes = %v, want %v", got, 9*110+10)
+	}
+
+	// 设置内存上限时默认使用反射估算
+	dc := NewCache[string, string](OptWithExpire(time.Second), OptWithMaxMemory("1MB"))
+	dc.Set("a", &long)
+	if got := dc.Stats().MemoryBytes; got < int64(len(long)) {
+		t.Errorf("Stats() MemoryBytes = %v, want >= %v", got, len(long))
+	}
+}
+
+func TestLCache_MaxEntrySize(t *testing.T) {
+	lc := NewCache[string, string](
+		OptWithExpire(time.Second),
+		OptWithMaxEntrySize(100),
+		OptWithSizeEstimator(func(value *string) int {
+			return len(*value)
+		}),
+	)
+	defer lc.Close()
+
+	small, large := "small", string(make([]byte, 1000))
+	lc.Set("a", &small)
+	lc.Set("b", &large)
+	if err := lc.TrySet("c", &large); err != ErrEntryTooLarge {
+		t.Errorf("TrySet() err = %v, want %v", err, ErrEntryTooLarge)
+	}
+
+	tests := []struct {
+		key    string
+		wantOk bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", false},
+	}
+	for _, tt := range tests {
+		if _, ok := lc.Get(tt.key); ok != tt.wantOk {
+			t.Errorf("Get(%v) gotOk = %v, want %v", tt.key, ok, tt.wantOk)
+		}
+	}
+	if got := lc.Stats().Rejected; got != 2 {
+		t.Errorf("Stats() Rejected = %v, want %v", got, 2)
+	}
+
+	// 超过上限的value覆盖写同样被拒绝，原来的value保持不变
+	lc.Set("a", &large)
+	if v, ok := lc.Get("a"); !ok || v != &small {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, &small, true)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"2KB", 2 << 10, false},
+		{"512mb", 512 << 20, false},
+		{"10 GB", 10 << 30, false},
+		{"1.5GB", 3 << 29, false},
+		{" 1TB ", 1 << 40, false},
+		{"100B", 100, false},
+		{"100", 100, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"GB", 0, true},
+		{"-1MB", 0, true},
+		{"1.2.3MB", 0, true},
+		{"10XB", 0, true},
+		{"1e3MB", 0, true},
+		{"99999999999TB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseMemory(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMemory() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseMemory() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCacheWithError(t *testing.T) {
+	if _, err := NewCacheWithError[string, int](OptWithMaxMemory("abc")); err == nil {
+		t.Errorf("NewCacheWithError() err = %v, want non-nil", err)
+	}
+
+	lc, err := NewCacheWithError[string, int](OptWithMaxMemory("1.5 kb"))
+	if err != nil {
+		t.Fatalf("NewCacheWithError() err = %v, want nil", err)
+	}
+	defer lc.Close()
+	if lc.o.maxMemory != 1536 {
+		t.Errorf("maxMemory = %v, want %v", lc.o.maxMemory, 1536)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("NewCache() did not panic on malformed max memory")
+		}
+	}()
+	NewCache[string, int](OptWithMaxMemory("10 XB"))
+}
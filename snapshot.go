@@ -0,0 +1,190 @@
+package localcache
+
+import (
+	"encoding/gob"
+	"io"
+	"time"
+)
+
+// snapshotEntry SaveToWriter保存的一个key
+type snapshotEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	TTL       time.Duration // 过期时间
+	Remaining time.Duration // 保存时距离过期的剩余时间
+	Persist   bool          // 永不过期
+}
+
+// SaveToWriter 使用encoding/gob将所有未过期的key、value以及剩余的过期时间写入w
+// K和V必须能被gob编码，V中未导出的字段不会被保存；值为nil的value按V的零值保存
+// 缓存内容在一次读锁内读取，编码在锁外进行
+func (lc *LCache[K, V]) SaveToWriter(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(lc.snapshotEntries(time.Now()))
+}
+
+// snapshotEntries 读取所有需要保存的key
+func (lc *LCache[K, V]) snapshotEntries(now time.Time) []snapshotEntry[K, V] {
+	var entries []snapshotEntry[K, V]
+	if lc.shards != nil {
+		for _, shard := range lc.shards {
+			entries = append(entries, shard.snapshotEntries(now)...)
+		}
+		return entries
+	}
+	lc.rlock()
+	defer lc.lock.RUnlock()
+	// Seal之后快照中的key永不过期
+	if lc.rangeSealed(func(k K, e sealedEntry[K, V]) bool {
+		entries = append(entries, snapshotEntry[K, V]{Key: k, Value: *e.v, Persist: true})
+		return true
+	}) {
+		return entries
+	}
+
+	entries = make([]snapshotEntry[K, V], 0, len(lc.kvStore))
+	for k, n := range lc.kvStore {
+		// 负缓存只在进程内有效，不保存
+		if n.rmFlag.Load() || !lc.listed(n, now) {
+			continue
+		}
+		e := snapshotEntry[K, V]{
+			Key:       k,
+			TTL:       n.exp.Load(),
+			Remaining: n.exp.Load(),
+			Persist:   n.persist.Load(),
+		}
+		if expAt := n.expAt.Load(); !expAt.IsZero() {
+			e.Remaining = expAt.Sub(now)
+		}
+		if n.v != nil {
+			e.Value = *n.v
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// MergePolicy LoadFromReader读取的key在缓存中已经存在时的处理方式
+type MergePolicy int
+
+const (
+	MergeOverwrite    MergePolicy = iota // 用读取的value覆盖已有的value
+	MergeSkipExisting                    // 保留已有的value，只写入缓存中不存在的key
+	MergeKeepNewer                       // 保留剩余时间较长的一个，永不过期视为最长，相同时保留已有的value
+)
+
+// LoadFromReader 读取SaveToWriter保存的内容并写入缓存，按保存时的剩余时间重新计算过期时刻
+// 缓存中已经存在且未过期的key由policy决定是否覆盖，可以把快照合并到正在使用的缓存中
+// 已经过期的key会被丢弃，未通过校验的value会被跳过；缓存已经Seal时返回ErrSealed
+// 保存的key已经经过OptWithKeyTransform转换，两个缓存应使用相同的转换
+func (lc *LCache[K, V]) LoadFromReader(r io.Reader, policy MergePolicy) error {
+	var entries []snapshotEntry[K, V]
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	return lc.loadEntries(entries, policy)
+}
+
+// loadEntries 写入LoadFromReader读取的key
+func (lc *LCache[K, V]) loadEntries(entries []snapshotEntry[K, V], policy MergePolicy) error {
+	if lc.shards != nil {
+		batches := make(map[*LCache[K, V]][]snapshotEntry[K, V])
+		for _, e := range entries {
+			shard := lc.shardOf(e.Key)
+			batches[shard] = append(batches[shard], e)
+		}
+		var err error
+		for shard, batch := range batches {
+			if e := shard.loadEntries(batch, policy); e != nil {
+				err = e
+			}
+		}
+		return err
+	}
+
+	// 剩余时间与过期时间不同的节点，在asyncJob中调整过期时刻
+	type restore struct {
+		n     *lruNode[K, V]
+		expAt time.Time
+	}
+	var (
+		restored []restore
+		replaced []evicted[K, V]
+	)
+	now := time.Now()
+
+	lc.wlock()
+	if lc.sealed.Load() != nil {
+		lc.lock.Unlock()
+		return ErrSealed
+	}
+	for i := range entries {
+		e := &entries[i]
+		if !e.Persist && e.Remaining <= 0 {
+			continue
+		}
+		if n, ok := lc.kvStore[e.Key]; ok && !lc.expired(n, now) && !lc.shouldMerge(n, e, policy, now) {
+			continue
+		}
+		// 每个value单独分配，避免缓存中的value共同持有整个entries
+		value := e.Value
+		if lc.validate(&value) != nil {
+			continue
+		}
+		old, ok := lc.store(e.Key, &value, func(n *lruNode[K, V]) {
+			n.exp.Store(e.TTL)
+			n.persist.Store(e.Persist)
+		})
+		if ok {
+			replaced = append(replaced, old)
+		}
+		if !e.Persist && e.Remaining != e.TTL {
+			restored = append(restored, restore{lc.kvStore[e.Key], now.Add(e.Remaining)})
+		}
+	}
+	lc.lock.Unlock()
+
+	for _, e := range replaced {
+		lc.evict(e)
+	}
+
+	if len(restored) == 0 {
+		return nil
+	}
+	lc.runJob(func() {
+		lc.lock.Lock()
+		defer lc.lock.Unlock()
+
+		for _, e := range restored {
+			// 已经被删除的节点不需要调整
+			if e.n.prev == nil {
+				continue
+			}
+			e.n.expAt.Store(e.expAt)
+			lc.expq.update(e.n)
+		}
+	})
+	return nil
+}
+
+// shouldMerge 返回读取的e是否应该覆盖缓存中未过期的n，调用方需持有写锁
+func (lc *LCache[K, V]) shouldMerge(n *lruNode[K, V], e *snapshotEntry[K, V], policy MergePolicy, now time.Time) bool {
+	switch policy {
+	case MergeSkipExisting:
+		return false
+	case MergeKeepNewer:
+		if n.persist.Load() {
+			return false
+		}
+		if e.Persist {
+			return true
+		}
+		// 刚写入的节点还没有在asyncJob中计算过期时刻，剩余时间就是它的过期时间
+		remaining := n.exp.Load()
+		if expAt := n.expAt.Load(); !n.rearm.Load() && !expAt.IsZero() {
+			remaining = expAt.Sub(now)
+		}
+		return e.Remaining > remaining
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package localcache
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type snapshotTestValue struct {
+	Name  string
+	Count int
+}
+
+func TestLCache_SaveLoad(t *testing.T) {
+	src := NewCache[string, snapshotTestValue](OptWithExpire(time.Second))
+	defer src.Close()
+
+	src.Set("a", &snapshotTestValue{"a", 1})
+	src.SetWithTTL("b", &snapshotTestValue{"b", 0}, time.Millisecond*500)
+	src.SetWithTTL("expired", &snapshotTestValue{"expired", 3}, time.Millisecond*50)
+	time.Sleep(time.Millisecond * 100)
+
+	var buf bytes.Buffer
+	if err := src.SaveToWriter(&buf); err != nil {
+		t.Fatalf("SaveToWriter() err = %v", err)
+	}
+
+	dst := NewCache[string, snapshotTestValue](OptWithExpire(time.Second))
+	defer dst.Close()
+	if err := dst.LoadFromReader(&buf, MergeOverwrite); err != nil {
+		t.Fatalf("LoadFromReader() err = %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		want   snapshotTestValue
+		remain time.Duration
+	}{
+		{"a", snapshotTestValue{"a", 1}, time.Millisecond * 900},
+		{"b", snapshotTestValue{"b", 0}, time.Millisecond * 400},
+	}
+	for _, tt := range tests {
+		v, ok := dst.Peek(tt.key)
+		if !ok || *v != tt.want {
+			t.Errorf("Peek(%v) = %v, %v, want %v, %v", tt.key, v, ok, tt.want, true)
+		}
+		got, _ := dst.TTL(tt.key)
+		if got > tt.remain || got < tt.remain-time.Millisecond*100 {
+			t.Errorf("TTL(%v) = %v, want about %v", tt.key, got, tt.remain)
+		}
+	}
+	if dst.Contains("expired") {
+		t.Errorf("Contains(expired) = %v, want %v", true, false)
+	}
+	if got := dst.Len(); got != 2 {
+		t.Errorf("Len() = %v, want %v", got, 2)
+	}
+}
+
+func TestLCache_LoadMerge(t *testing.T) {
+	src := NewCache[string, snapshotTestValue](OptWithExpire(time.Second))
+	defer src.Close()
+	src.SetWithTTL("a", &snapshotTestValue{"src", 1}, time.Second*2)
+	src.SetWithTTL("b", &snapshotTestValue{"src", 2}, time.Millisecond*200)
+	src.Set("c", &snapshotTestValue{"src", 3})
+
+	var buf bytes.Buffer
+	if err := src.SaveToWriter(&buf); err != nil {
+		t.Fatalf("SaveToWriter() err = %v", err)
+	}
+	snapshot := buf.Bytes()
+
+	tests := []struct {
+		policy MergePolicy
+		want   map[string]string // key对应的value来自src还是dst
+	}{
+		{MergeOverwrite, map[string]string{"a": "src", "b": "src", "c": "src", "d": "dst"}},
+		{MergeSkipExisting, map[string]string{"a": "dst", "b": "dst", "c": "src", "d": "dst"}},
+		{MergeKeepNewer, map[string]string{"a": "src", "b": "dst", "c": "src", "d": "dst"}},
+	}
+	for _, tt := range tests {
+		dst := NewCache[string, snapshotTestValue](OptWithExpire(time.Second))
+		dst.Set("a", &snapshotTestValue{"dst", 1})
+		dst.Set("b", &snapshotTestValue{"dst", 2})
+		dst.Set("d", &snapshotTestValue{"dst", 4})
+
+		if err := dst.LoadFromReader(bytes.NewReader(snapshot), tt.policy); err != nil {
+			t.Fatalf("LoadFromReader(%v) err = %v", tt.policy, err)
+		}
+		for key, want := range tt.want {
+			if v, ok := dst.Peek(key); !ok || v.Name != want {
+				t.Errorf("LoadFromReader(%v) Peek(%v) = %v, %v, want %v", tt.policy, key, v, ok, want)
+			}
+		}
+		if got := dst.Len(); got != 4 {
+			t.Errorf("LoadFromReader(%v) Len() = %v, want %v", tt.policy, got, 4)
+		}
+		dst.Close()
+	}
+}
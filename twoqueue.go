@@ -0,0 +1,83 @@
+package localcache
+
+// defaultProbation TwoQueue策略中试用队列默认占容量的比例
+const defaultProbation = 0.25
+
+// OptWithTwoQueueRatio 设置TwoQueue策略中试用队列占容量的比例，取值范围为(0, 1)，默认为0.25
+// 试用队列超出这个比例时优先从中淘汰最早写入且没有被再次访问的key，否则从主队列中按lru淘汰
+func OptWithTwoQueueRatio(probation float64) Option {
+	return func(co *CacheOptions) {
+		co.probation = probation
+	}
+}
+
+// admit 节点被放到lru表头时调用：已经被再次访问过的节点进入主队列，其他节点放到试用队列表头
+func (lc *LCache[K, V]) admit(n *lruNode[K, V]) {
+	if n.promoted {
+		return
+	}
+	if n.accessCount.Load() > 0 {
+		n.promoted = true
+		return
+	}
+	n.qprev = nil
+	n.qnext = lc.probHead
+	if lc.probHead != nil {
+		lc.probHead.qprev = n
+	} else {
+		lc.probTail = n
+	}
+	lc.probHead = n
+	n.inProbation = true
+	lc.probLen++
+}
+
+// leaveProbation 将n从试用队列中摘除
+func (lc *LCache[K, V]) leaveProbation(n *lruNode[K, V]) {
+	if n.qprev != nil {
+		n.qprev.qnext = n.qnext
+	} else {
+		lc.probHead = n.qnext
+	}
+	if n.qnext != nil {
+		n.qnext.qprev = n.qprev
+	} else {
+		lc.probTail = n.qprev
+	}
+	n.qprev = nil
+	n.qnext = nil
+	n.inProbation = false
+	lc.probLen--
+}
+
+// probationLimit 试用队列的容量，未设置key数量上限时按lru链表当前的长度计算
+func (lc *LCache[K, V]) probationLimit() int {
+	ratio := lc.o.probation
+	if ratio <= 0 || ratio >= 1 {
+		ratio = defaultProbation
+	}
+	base := lc.o.max
+	if base <= 0 {
+		base = lc.lruLen
+	}
+	if limit := int(float64(base) * ratio); limit > 0 {
+		return limit
+	}
+	return 1
+}
+
+// probationVictim 从试用队列表尾查找淘汰对象，途中遇到的已被再次访问的节点转入主队列
+// force为false时，只有试用队列超出容量才返回淘汰对象
+func (lc *LCache[K, V]) probationVictim(force bool) *lruNode[K, V] {
+	if !force && lc.probLen <= lc.probationLimit() {
+		return nil
+	}
+	for n := lc.probTail; n != nil; n = lc.probTail {
+		if n.accessCount.Load() == 0 {
+			return n
+		}
+		lc.leaveProbation(n)
+		n.promoted = true
+	}
+	return nil
+}
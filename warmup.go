@@ -0,0 +1,94 @@
+package localcache
+
+import "time"
+
+// WarmUp 批量写入启动时预加载的数据，所有key的过期时间均为ttl，设置了OptWithTTLJitter时在ttl附近随机浮动
+// 与逐个Set不同，map、lru链表以及过期时刻在asyncJob中一次性建立，不经过更新channel，适合一次写入大量key
+// 未通过校验的value会被跳过；已经存在的key被覆盖时触发原因为ReasonReplaced的OnEvict回调；缓存已经Seal时不做任何事
+func (lc *LCache[K, V]) WarmUp(items map[K]*V, ttl time.Duration) {
+	if lc.shards != nil {
+		batches := make(map[*LCache[K, V]]map[K]*V, len(lc.shards))
+		for k, v := range items {
+			shard, k := lc.route(k)
+			if batches[shard] == nil {
+				batches[shard] = make(map[K]*V)
+			}
+			batches[shard][k] = v
+		}
+		for shard, batch := range batches {
+			shard.WarmUp(batch, ttl)
+		}
+		return
+	}
+
+	lc.runJob(func() {
+		lc.lock.Lock()
+		if lc.sealed.Load() != nil {
+			lc.lock.Unlock()
+			return
+		}
+		var replaced []evicted[K, V]
+		now := time.Now()
+		for k, v := range items {
+			if lc.validate(v) != nil {
+				continue
+			}
+			if e, ok := lc.warmNode(lc.storageKey(k), v, lc.clampTTL(lc.jitterTTL(ttl)), now); ok {
+				replaced = append(replaced, e)
+			}
+		}
+		lc.lock.Unlock()
+
+		for _, e := range replaced {
+			e := e
+			lc.queueCall(func() { lc.evict(e) })
+		}
+		lc.evictOverflow()
+	})
+}
+
+// warmNode 写入key并直接放到lru表头、计算过期时刻，只在asyncJob中持有写锁时调用
+// key已经存在且value被替换时返回被替换的value
+func (lc *LCache[K, V]) warmNode(key K, value *V, ttl time.Duration, now time.Time) (old evicted[K, V], replaced bool) {
+	n, ok := lc.kvStore[key]
+	if ok && n.v != value {
+		old = evicted[K, V]{k: key, v: n.v, reason: ReasonReplaced}
+		replaced = true
+	}
+	if !ok {
+		n = &lruNode[K, V]{
+			k:       key,
+			created: now,
+		}
+		lc.kvStore[key] = n
+		lc.keyCounter += 1
+	}
+	n.v = value
+	lc.bumpVersion(n)
+	n.lastSet = now
+	if lc.sizer != nil {
+		size := lc.sizer(value)
+		lc.stats.memory.Add(int64(size - n.size))
+		n.size = size
+	}
+	n.exp.Store(ttl)
+	n.prio = 0
+	n.negative = false
+	n.onExpire = nil
+	n.persist.Store(false)
+	n.rearm.Store(false)
+	n.expAt.Store(now.Add(ttl))
+
+	if lc.o.missTrack > 0 {
+		lc.missLock.Lock()
+		delete(lc.missCounts, key)
+		lc.missLock.Unlock()
+	}
+	delete(lc.ghosts, key)
+	lc.stats.sets.Add(1)
+
+	lc.unlinkNode(n)
+	lc.linkHead(n)
+	lc.expq.update(n)
+	return old, replaced
+}
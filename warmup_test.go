@@ -0,0 +1,115 @@
+package localcache
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLCache_WarmUp(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"single", nil},
+		{"shards", []Option{OptWithShards(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lc := NewCache[int, int](append(tt.opts, OptWithExpire(time.Minute), OptWithCleanupInterval(time.Millisecond*20))...)
+			defer lc.Close()
+
+			const n = 10000
+			items := make(map[int]*int, n)
+			for i := 0; i < n; i++ {
+				v := i
+				items[i] = &v
+			}
+			lc.WarmUp(items, time.Millisecond*200)
+
+			if got := lc.Len(); got != n {
+				t.Errorf("Len() = %v, want %v", got, n)
+			}
+			if got := lc.Stats().ChannelDepth; got != 0 {
+				t.Errorf("Stats() ChannelDepth = %v, want %v", got, 0)
+			}
+			for i := 0; i < n; i++ {
+				if v, ok := lc.Peek(i); !ok || *v != i {
+					t.Fatalf("Peek(%d) = %v, %v, want %v, %v", i, v, ok, i, true)
+				}
+			}
+			if ttl, ok := lc.TTL(0); !ok || ttl <= 0 || ttl > time.Millisecond*200 {
+				t.Errorf("TTL() = %v, %v, want in (0, %v]", ttl, ok, time.Millisecond*200)
+			}
+
+			time.Sleep(time.Millisecond * 400)
+			if got := lc.Len(); got != 0 {
+				t.Errorf("Len() = %v, want %v", got, 0)
+			}
+			if got := lc.Stats().Expirations; got != n {
+				t.Errorf("Stats() Expirations = %v, want %v", got, n)
+			}
+		})
+	}
+}
+
+func TestLCache_WarmUpReplace(t *testing.T) {
+	var replaced []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Minute),
+		OptWithMaxKeys(3),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			if reason == ReasonReplaced {
+				replaced = append(replaced, key)
+			}
+		}),
+	)
+	defer lc.Close()
+
+	v1, v2 := 1, 2
+	lc.Set("a", &v1)
+	lc.WarmUp(map[string]*int{"a": &v2, "b": &v2}, time.Minute)
+	if v, ok := lc.Get("a"); !ok || v != &v2 {
+		t.Errorf("Get() = %v, %v, want %v, %v", v, ok, &v2, true)
+	}
+	if got := lc.Len(); got != 2 {
+		t.Errorf("Len() = %v, want %v", got, 2)
+	}
+
+	// 超出容量的部分按lru淘汰
+	lc.WarmUp(map[string]*int{"c": &v1, "d": &v1, "e": &v1}, time.Minute)
+	lc.DebugString()
+	if got := lc.Len(); got != 3 {
+		t.Errorf("Len() = %v, want %v", got, 3)
+	}
+	lc.Close()
+	if len(replaced) != 1 || replaced[0] != "a" {
+		t.Errorf("OnEvict() replaced = %v, want %v", replaced, []string{"a"})
+	}
+}
+
+func TestLCache_WarmUpReplaceKeys(t *testing.T) {
+	var replaced []string
+	lc := NewCache[string, int](
+		OptWithExpire(time.Minute),
+		OptWithOnEvict(func(key string, value *int, reason EvictReason) {
+			if reason == ReasonReplaced {
+				replaced = append(replaced, key)
+			}
+		}),
+	)
+
+	v1, v2 := 1, 2
+	for _, k := range []string{"a", "b", "c"} {
+		lc.Set(k, &v1)
+	}
+	lc.WarmUp(map[string]*int{"a": &v2, "b": &v2, "c": &v2}, time.Minute)
+	lc.Close()
+
+	// 每个被替换的key各触发一次回调
+	sort.Strings(replaced)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(replaced, want) {
+		t.Errorf("OnEvict() replaced = %v, want %v", replaced, want)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build go1.24
+
+package localcache
+
+import "weak"
+
+// weakSupported 当前Go版本是否支持弱引用
+const weakSupported = true
+
+// weakRef value的弱引用，不阻止GC回收value
+type weakRef[V any] struct {
+	p weak.Pointer[V]
+}
+
+func makeWeakRef[V any](v *V) weakRef[V] {
+	return weakRef[V]{p: weak.Make(v)}
+}
+
+// value 返回value，已经被GC回收时返回nil
+func (r weakRef[V]) value() *V {
+	return r.p.Value()
+}
@@ -0,0 +1,16 @@
+//go:build !go1.24
+
+package localcache
+
+// weakSupported 当前Go版本是否支持弱引用，低于go1.24时OptWithWeakValues不生效
+const weakSupported = false
+
+type weakRef[V any] struct{}
+
+func makeWeakRef[V any](v *V) weakRef[V] {
+	return weakRef[V]{}
+}
+
+func (r weakRef[V]) value() *V {
+	return nil
+}
@@ -0,0 +1,56 @@
+//go:build go1.24
+
+package localcache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+type bigValue struct {
+	buf [1 << 16]byte
+	n   int
+}
+
+func TestLCache_WeakValues(t *testing.T) {
+	lc := NewCache[int, bigValue](OptWithExpire(time.Minute), OptWithMaxKeys(1), OptWithWeakValues(true))
+
+	// 保留强引用时，被淘汰的value可以通过弱引用找回
+	kept := &bigValue{n: 1}
+	lc.Set(1, kept)
+	lc.Set(2, &bigValue{n: 2})
+	lc.DebugString()
+	if _, ok := lc.kvStoreGet(1); ok {
+		t.Fatalf("key 1 should be evicted")
+	}
+	runtime.GC()
+	if v, ok := lc.Get(1); !ok || v != kept {
+		t.Errorf("Get() = %v, %v, want soft hit", v, ok)
+	}
+	if got := lc.Stats().SoftHits; got != 1 {
+		t.Errorf("Stats().SoftHits = %v, want 1", got)
+	}
+	runtime.KeepAlive(kept)
+
+	// 没有强引用时，GC之后value被回收
+	lc.Set(3, &bigValue{n: 3})
+	lc.Set(4, &bigValue{n: 4})
+	lc.DebugString()
+	runtime.GC()
+	runtime.GC()
+	if _, ok := lc.Get(3); ok {
+		t.Errorf("Get() gotOk = %v, want %v", ok, false)
+	}
+	if got := lc.Stats().SoftHits; got != 1 {
+		t.Errorf("Stats().SoftHits = %v, want 1", got)
+	}
+}
+
+// kvStoreGet 直接读取map，不触发弱引用找回
+func (lc *LCache[K, V]) kvStoreGet(key K) (*lruNode[K, V], bool) {
+	lc.lock.RLock()
+	defer lc.lock.RUnlock()
+	n, ok := lc.kvStore[key]
+	return n, ok
+}